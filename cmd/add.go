@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add someone to the saved draw",
+	Long: `Adds someone who joined late to the saved draw without drawing names again.
+They must be in the participants file. One gifter who was buying for someone
+else buys for them instead, and they buy for that person, so only the
+newcomer and that gifter are sent an assignment. Every exclusion is kept.
+With several gifts each, that happens once per gift. A dry run prints the
+new assignments without saving or sending anything.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sender, messages, drawPath, participantsPath := openSavedDraw(cmd)
+		if err := addParticipant(cmd.Context(), sender, messages, drawPath, participantsPath, args[0]); err != nil {
+			fatal(exitCode(err), "error adding to the draw", "name", args[0], "err", err)
+		}
+		output.setDraw(sender.Draw)
+		if drawPath == "" {
+			slog.Info("dry run, nothing was saved or sent")
+			return
+		}
+		slog.Info("added to the draw", "name", args[0])
+	},
+}
+
+func init() {
+	addDeliveryFlags(addCmd.Flags())
+	addCmd.Flags().StringP("participants", "p", "", "a csv file with participants (required)")
+	addCmd.Flags().StringP("wishlists", "w", "", "a directory of wishlist csv files named after each participant, e.g. Fred.csv")
+	rootCmd.AddCommand(addCmd)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"log/slog"
+
+	csvLoader "github.com/dcmcand/go-secret-santa/package/csvparticipantloader"
+	"github.com/dcmcand/go-secret-santa/package/relay"
+	"github.com/dcmcand/go-secret-santa/package/send"
+
+	"github.com/spf13/cobra"
+)
+
+var dropCmd = &cobra.Command{
+	Use:   "drop <name>",
+	Short: "Take someone out of the saved draw",
+	Long: `Takes someone who can no longer take part out of the saved draw without
+drawing names again. Whoever was buying for them buys for their giftee
+instead, unless that breaks an exclusion, in which case they swap giftees
+with someone else. Only the people whose assignment changed are sent their
+new one. A dry run prints the new assignments without saving or sending
+anything.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sender, messages, drawPath, participantsPath := openSavedDraw(cmd)
+		if err := dropParticipant(cmd.Context(), sender, messages, drawPath, participantsPath, args[0]); err != nil {
+			fatal(exitCode(err), "error taking someone out of the draw", "name", args[0], "err", err)
+		}
+		output.setDraw(sender.Draw)
+		if drawPath == "" {
+			slog.Info("dry run, nothing was saved or sent")
+			return
+		}
+		slog.Info("taken out of the draw", "name", args[0])
+	},
+}
+
+// openSavedDraw sets up a sender for the saved draw, for commands that
+// repair it. Dry runs return an empty draw path so nothing is saved.
+func openSavedDraw(cmd *cobra.Command) (*send.Sender, *relay.Relay, string, string) {
+	configPath, participantsPath, err := getConfigurationFiles(cmd)
+	if err != nil {
+		fatal(exitConfig, "error getting configuration files", "err", err)
+	}
+	if err := checkConfigFiles(configPath, participantsPath); err != nil {
+		fatal(exitConfig, "error checking config files", "err", err)
+	}
+	initConfig(configPath)
+
+	wishlistDir, _ := cmd.Flags().GetString("wishlists")
+	sender, messages := newSender(cmd, &csvLoader.Loader{WishlistDir: wishlistDir})
+	drawPath, _ := cmd.Flags().GetString("draw")
+	if sender.Draw, err = send.LoadDraw(drawPath); err != nil {
+		fatal(exitDraw, "no saved draw to change", "err", err)
+	}
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	output.setDraw(sender.Draw)
+	output.track(sender, dryRun)
+	if dryRun {
+		return sender, messages, "", participantsPath
+	}
+	journalPath, _ := cmd.Flags().GetString("journal")
+	if sender.Journal, err = send.OpenJournal(journalPath); err != nil {
+		fatal(exitError, "error opening the journal", "err", err)
+	}
+	return sender, messages, drawPath, participantsPath
+}
+
+func init() {
+	addDeliveryFlags(dropCmd.Flags())
+	dropCmd.Flags().StringP("participants", "p", "", "a csv file with participants (required)")
+	dropCmd.Flags().StringP("wishlists", "w", "", "a directory of wishlist csv files named after each participant, e.g. Fred.csv")
+	rootCmd.AddCommand(dropCmd)
+}
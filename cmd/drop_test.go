@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+func TestDropDryRun(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	participantsPath := filepath.Join(dir, "participants.csv")
+	drawPath := filepath.Join(dir, "draw.json")
+	journalPath := filepath.Join(dir, "journal.jsonl")
+	files := map[string]string{
+		configPath:       "email:\n  domain: bedrock.com\n",
+		participantsPath: "Name,Email\nFred,fred@bedrock.com\nWilma,wilma@bedrock.com\nBarney,barney@bedrock.com\nBetty,betty@bedrock.com\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	draw := &send.Draw{ID: "a", Created: time.Now(), Assignment: send.Assignment{Draw: "a", GiftsPerPerson: 1, Pairs: []send.Pair{
+		{Gifter: "Barney", Giftees: []string{"Betty"}},
+		{Gifter: "Betty", Giftees: []string{"Fred"}},
+		{Gifter: "Fred", Giftees: []string{"Wilma"}},
+		{Gifter: "Wilma", Giftees: []string{"Barney"}},
+	}}}
+	if err := draw.Save(drawPath); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(drawPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd.SetArgs([]string{"drop", "Wilma", "--dry-run", "--output", "json",
+		"-c", configPath, "-p", participantsPath, "--draw", drawPath, "--journal", journalPath})
+	stdout, stderr := capture(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("drop error = %v", err)
+		}
+	})
+
+	var got result
+	if err := json.Unmarshal(stdout, &got); err != nil {
+		t.Fatalf("drop wrote %q, not a JSON result: %v", stdout, err)
+	}
+	// Fred bought for Wilma, so only Fred's assignment changes
+	if !got.DryRun || len(got.Participants) != 1 || got.Participants[0].Name != "Fred" || got.Participants[0].Status != "printed" {
+		t.Errorf("drop result = %+v, want only Fred printed", got)
+	}
+	if !bytes.Contains(stderr, []byte("Email to Fred")) || !bytes.Contains(stderr, []byte("buy a gift for Barney")) {
+		t.Errorf("Fred's new assignment wasn't printed:\n%s", stderr)
+	}
+	if after, err := os.ReadFile(drawPath); err != nil || !bytes.Equal(after, saved) {
+		t.Errorf("the dry run changed the saved draw: %s, %v", after, err)
+	}
+	if _, err := os.Stat(journalPath); err == nil {
+		t.Errorf("the dry run wrote a journal")
+	}
+}
+
+// capture returns what f writes to stdout and stderr.
+func capture(t *testing.T, f func()) ([]byte, []byte) {
+	t.Helper()
+	stdout, stderr := os.Stdout, os.Stderr
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout, os.Stderr = outW, errW
+	outC, errC := make(chan []byte), make(chan []byte)
+	go func() { b, _ := io.ReadAll(outR); outC <- b }()
+	go func() { b, _ := io.ReadAll(errR); errC <- b }()
+	f()
+	outW.Close()
+	errW.Close()
+	return <-outC, <-errC
+}
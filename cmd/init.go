@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/dcmcand/go-secret-santa/package/conf"
+	"github.com/dcmcand/go-secret-santa/package/send"
+
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a config file and participants file",
+	Long: `Walks through the email provider, sender and event details and then
+	lets you add participants one at a time. Any value given as a flag is
+	offered as the default. With --non-interactive the files are written
+	from the flags alone, which is useful for scripting.
+	Existing files are never replaced unless --force is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath, participantsPath, err := getConfigurationFiles(cmd)
+		if err != nil {
+			fatal(exitConfig, "error getting configuration files", "err", err)
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		nonInteractive, _ := cmd.Flags().GetBool("non-interactive")
+
+		settings, participants, err := initSettingsFromFlags(cmd)
+		if err != nil {
+			fatal(exitUsage, "error reading flags", "err", err)
+		}
+
+		// Fail before asking any questions if the files can't be written
+		for _, path := range []string{configPath, participantsPath} {
+			if _, err := os.Stat(path); err == nil && !force {
+				fatal(exitConfig, "file already exists, use --force to overwrite it", "path", path)
+			}
+		}
+
+		if nonInteractive {
+			if settings.SenderAddress == "" && settings.Domain != "" {
+				settings.SenderAddress = "santa@" + settings.Domain
+			}
+			if err := conf.ValidateSettings(settings); err != nil {
+				fatal(exitConfig, "invalid settings", "err", err)
+			}
+		} else {
+			wizard := conf.NewWizard(os.Stdin, humanOutput())
+			settings, err = wizard.AskSettings(settings)
+			if err == nil {
+				participants, err = wizard.AskParticipants(participants)
+			}
+			if err != nil {
+				fatal(exitError, "error running setup", "err", err)
+			}
+		}
+		if err := conf.ValidateParticipants(participants); err != nil {
+			fatal(exitConfig, "invalid participants", "err", err)
+		}
+
+		if err := conf.WriteConfig(configPath, settings, force); err != nil {
+			fatal(exitError, "error writing config file", "err", err)
+		}
+		if err := conf.WriteParticipants(participantsPath, participants, force); err != nil {
+			fatal(exitError, "error writing participants file", "err", err)
+		}
+		slog.Info("wrote config and participants files", "config", configPath, "participants", participantsPath, "count", len(participants))
+		if output != nil {
+			for _, p := range participants {
+				output.Participants = append(output.Participants, participantResult{Name: p.Name, Status: "written"})
+			}
+		}
+	},
+}
+
+func initSettingsFromFlags(cmd *cobra.Command) (conf.Settings, []send.Participant, error) {
+	flags := cmd.Flags()
+	settings := conf.Settings{}
+	settings.Provider, _ = flags.GetString("provider")
+	settings.APIKey, _ = flags.GetString("api-key")
+	settings.Secret, _ = flags.GetString("secret")
+	settings.Region, _ = flags.GetString("region")
+	settings.Domain, _ = flags.GetString("domain")
+	settings.Subject, _ = flags.GetString("subject")
+	settings.SenderName, _ = flags.GetString("sender-name")
+	settings.SenderAddress, _ = flags.GetString("sender-address")
+	settings.Event.Name, _ = flags.GetString("event-name")
+	settings.Event.Date, _ = flags.GetString("event-date")
+	settings.Event.Deadline, _ = flags.GetString("gift-deadline")
+	settings.Event.Location, _ = flags.GetString("event-location")
+	settings.Event.Budget, _ = flags.GetString("budget")
+
+	raw, err := flags.GetStringArray("participant")
+	if err != nil {
+		return settings, nil, err
+	}
+	participants := make([]send.Participant, 0, len(raw))
+	for _, r := range raw {
+		p, err := conf.ParseParticipant(r)
+		if err != nil {
+			return settings, nil, err
+		}
+		participants = append(participants, p)
+	}
+	return settings, participants, nil
+}
+
+func init() {
+	initCmd.Flags().StringP("participants", "p", "", "where to write the participants csv file (default ./participants.csv)")
+	initCmd.Flags().StringP("config", "c", "", "where to write the config file (default ./config.yaml)")
+	initCmd.Flags().BoolP("force", "f", false, "overwrite existing config and participants files")
+	initCmd.Flags().BoolP("non-interactive", "y", false, "don't prompt, build the files from flags only")
+	initCmd.Flags().String("provider", "mailgun", "the email provider used to send assignments ("+strings.Join(conf.Providers, ", ")+")")
+	initCmd.Flags().String("api-key", "", "the api key for the email provider, or the access key id for ses")
+	initCmd.Flags().String("secret", "", "the secret access key for ses")
+	initCmd.Flags().String("region", "", "the region to send from with ses")
+	initCmd.Flags().String("domain", "", "the domain email is sent from")
+	initCmd.Flags().String("subject", "Secret Santa Assignment", "the subject of the assignment email")
+	initCmd.Flags().String("sender-name", "Santa Claus", "the name assignments are sent from")
+	initCmd.Flags().String("sender-address", "", "the address assignments are sent from (default santa@<domain>)")
+	initCmd.Flags().String("event-name", "Secret Santa", "the name of the gift exchange")
+	initCmd.Flags().String("event-date", "", "the date of the gift exchange (YYYY-MM-DD)")
+	initCmd.Flags().String("gift-deadline", "", "the date gifts should be bought by (YYYY-MM-DD)")
+	initCmd.Flags().String("event-location", "", "where the gift exchange takes place")
+	initCmd.Flags().String("budget", "", "the suggested spend per gift")
+	initCmd.Flags().StringArray("participant", nil, `a participant as "Name,Email,Partner,Interests", can be repeated`)
+	rootCmd.AddCommand(initCmd)
+}
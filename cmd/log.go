@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// setupLogging sends logs to stderr at --log-level in --log-format. Nothing
+// logged at any level includes who buys for whom.
+func setupLogging(cmd *cobra.Command, args []string) error {
+	levelFlag, _ := cmd.Flags().GetString("log-level")
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(levelFlag)); err != nil {
+		return fmt.Errorf("unknown log level %q, choose debug, info, warn or error", levelFlag)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format, _ := cmd.Flags().GetString("log-format"); format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q, choose text or json", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Exit codes, so scripts can tell what kind of failure stopped a command.
+// They are listed in the README and must not change.
+const (
+	exitOK          = 0
+	exitError       = 1 // anything not covered below
+	exitUsage       = 2 // unknown flags, bad flag values or arguments
+	exitConfig      = 3 // the config, participants or template files are missing or invalid
+	exitDraw        = 4 // names can't be drawn, or the saved draw can't be used or changed
+	exitDelivery    = 5 // some assignments weren't delivered
+	exitInterrupted = 6 // stopped before every assignment was sent
+)
+
+var exitClasses = map[int]string{
+	exitError:       "error",
+	exitUsage:       "usage",
+	exitConfig:      "config",
+	exitDraw:        "draw",
+	exitDelivery:    "delivery",
+	exitInterrupted: "interrupted",
+}
+
+// result is written to stdout as JSON when a command finishes, with
+// --output json. It never says who buys for whom.
+type result struct {
+	Command      string              `json:"command"`
+	Status       string              `json:"status"`
+	DryRun       bool                `json:"dry_run"`
+	Draw         *drawResult         `json:"draw,omitempty"`
+	Participants []participantResult `json:"participants"`
+	Error        *errorResult        `json:"error,omitempty"`
+	ExitCode     int                 `json:"exit_code"`
+}
+
+type drawResult struct {
+	ID             string    `json:"id"`
+	Created        time.Time `json:"created"`
+	GiftsPerPerson int       `json:"gifts_per_person"`
+	Participants   int       `json:"participants"`
+}
+
+// participantResult is what happened to one participant. Status is one of
+// sent, printed (dry runs), already_sent, failed, maybe_sent, unsent,
+// written (init), or valid or unreachable (validate).
+type participantResult struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Channel   string `json:"channel,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type errorResult struct {
+	Class   string `json:"class"`
+	Message string `json:"message"`
+}
+
+// output is the result being built for --output json, nil otherwise.
+var output *result
+
+// setupOutput reads --output. With json, the result is written to stdout
+// and everything meant for people goes to stderr.
+func setupOutput(cmd *cobra.Command) error {
+	switch format, _ := cmd.Flags().GetString("output"); format {
+	case "text":
+		output = nil
+	case "json":
+		output = &result{Command: cmd.Name(), Participants: []participantResult{}}
+	default:
+		return fmt.Errorf("unknown output %q, choose text or json", format)
+	}
+	return nil
+}
+
+// humanOutput is where messages meant for people, such as dry run
+// printouts, are written.
+func humanOutput() io.Writer {
+	if output != nil {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// writeOutput writes the result of a command that finished.
+func writeOutput(cmd *cobra.Command, args []string) {
+	if output == nil {
+		return
+	}
+	output.Status, output.ExitCode = "ok", exitOK
+	output.write()
+}
+
+func (r *result) write() {
+	slices.SortFunc(r.Participants, func(a, b participantResult) int { return strings.Compare(a.Name, b.Name) })
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(r)
+}
+
+// setDraw records the draw a command used.
+func (r *result) setDraw(draw *send.Draw) {
+	if r == nil || draw == nil {
+		return
+	}
+	r.Draw = &drawResult{ID: draw.ID, Created: draw.Created, GiftsPerPerson: draw.Assignment.GiftsPerPerson, Participants: len(draw.Assignment.Pairs)}
+}
+
+// track records every delivery the sender makes.
+func (r *result) track(sender *send.Sender, dryRun bool) {
+	if r == nil {
+		return
+	}
+	r.DryRun = dryRun
+	sender.Delivered = func(d send.Delivery, err error) {
+		p := participantResult{Name: d.Gifter, Status: "sent", Channel: d.Channel, MessageID: d.MessageID}
+		if dryRun {
+			p.Status = "printed"
+		}
+		if err != nil {
+			p.Status, p.Error = "failed", err.Error()
+		}
+		r.Participants = append(r.Participants, p)
+	}
+}
+
+// finishDelivery fills in the gifters who weren't sent anything: those
+// stopped part way and those the journal already had.
+func (r *result) finishDelivery(sender *send.Sender, err error) {
+	if r == nil {
+		return
+	}
+	var report *send.DeliveryError
+	if errors.As(err, &report) {
+		// Stopped deliveries were tracked as failed, and those left
+		// pending by an earlier run weren't tracked at all
+		for _, name := range report.Unsent {
+			r.mark(name, "unsent")
+		}
+		for _, name := range report.MaybeSent {
+			r.mark(name, "maybe_sent")
+		}
+	}
+	if sender.Draw == nil || sender.Journal == nil {
+		return
+	}
+	for _, pair := range sender.Draw.Assignment.Pairs {
+		gifter := pair.Gifter
+		if slices.ContainsFunc(r.Participants, func(p participantResult) bool { return p.Name == gifter }) {
+			continue
+		}
+		if d, ok := sender.Journal.Delivery(sender.Draw.ID, gifter); ok {
+			r.Participants = append(r.Participants, participantResult{Name: gifter, Status: "already_sent", Channel: d.Channel, MessageID: d.MessageID})
+		}
+	}
+}
+
+// mark sets a participant's status, adding them if they aren't listed.
+func (r *result) mark(name, status string) {
+	i := slices.IndexFunc(r.Participants, func(p participantResult) bool { return p.Name == name })
+	if i < 0 {
+		r.Participants = append(r.Participants, participantResult{Name: name, Status: status})
+		return
+	}
+	r.Participants[i].Status = status
+}
+
+// fileError is a draw or journal file that couldn't be read or written,
+// which is no fault of the draw.
+type fileError struct{ err error }
+
+func (e fileError) Error() string { return e.err.Error() }
+func (e fileError) Unwrap() error { return e.err }
+
+// exitCode is the exit code for an error from drawing names, changing the
+// draw or sending assignments.
+func exitCode(err error) int {
+	var report *send.DeliveryError
+	var file fileError
+	switch {
+	case errors.Is(err, send.ErrParticipants), errors.Is(err, send.ErrNoNotifier):
+		return exitConfig
+	case errors.As(err, &file):
+		return exitError
+	case !errors.As(err, &report):
+		return exitDraw
+	case report.Cancelled != nil:
+		return exitInterrupted
+	}
+	return exitDelivery
+}
+
+// fatal logs an error and exits with code, writing the result first with
+// --output json.
+func fatal(code int, msg string, args ...any) {
+	slog.Error(msg, args...)
+	if output != nil {
+		message := msg
+		for i := 0; i+1 < len(args); i += 2 {
+			if args[i] == "err" {
+				message += ": " + fmt.Sprint(args[i+1])
+			}
+		}
+		output.Status = "failed"
+		output.Error = &errorResult{Class: exitClasses[code], Message: message}
+		output.ExitCode = code
+		output.write()
+	}
+	os.Exit(code)
+}
+
+// usageFailed reports an error cobra returned, which is always a bad flag
+// or argument. The flags may not have been read, so --output is looked for
+// in the arguments.
+func usageFailed(cmd *cobra.Command, err error) {
+	if output == nil {
+		flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+		flags.ParseErrorsWhitelist.UnknownFlags = true
+		flags.SetOutput(io.Discard)
+		format := flags.StringP("output", "o", "text", "")
+		flags.Parse(os.Args[1:])
+		if *format == "json" {
+			output = &result{Command: cmd.Name(), Participants: []participantResult{}}
+		}
+	}
+	fatal(exitUsage, "invalid usage", "err", err)
+}
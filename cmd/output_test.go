@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "Participants file", err: fmt.Errorf("%w: no such file", send.ErrParticipants), want: exitConfig},
+		{name: "No default notifier", err: fmt.Errorf("Fred %w", send.ErrNoNotifier), want: exitConfig},
+		{name: "Draw file", err: fileError{errors.New("error saving draw: read-only file system")}, want: exitError},
+		{name: "Draw", err: errors.New("Fred is not in the draw"), want: exitDraw},
+		{name: "Failed", err: fmt.Errorf("error sending email: %w", &send.DeliveryError{Failed: map[string]error{"Fred": errors.New("down")}}), want: exitDelivery},
+		{name: "Stopped mid send", err: fmt.Errorf("error sending email: %w", &send.DeliveryError{MaybeSent: []string{"Fred"}, Cancelled: context.Canceled}), want: exitInterrupted},
+		{name: "Stopped before sending", err: fmt.Errorf("error sending email: %w", &send.DeliveryError{Unsent: []string{"Fred"}, Cancelled: context.Canceled}), want: exitInterrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResult_finishDelivery(t *testing.T) {
+	r := &result{Participants: []participantResult{}}
+	sender := &send.Sender{}
+	r.track(sender, false)
+	sender.Delivered(send.Delivery{Gifter: "Fred", Channel: "email"}, nil)
+	sender.Delivered(send.Delivery{Gifter: "Wilma"}, context.Canceled)
+	sender.Delivered(send.Delivery{Gifter: "Barney"}, context.Canceled)
+	report := &send.DeliveryError{Sent: []string{"Fred"}, Unsent: []string{"Wilma"}, MaybeSent: []string{"Barney", "Betty"}, Cancelled: context.Canceled}
+	r.finishDelivery(sender, fmt.Errorf("error sending email: %w", report))
+
+	got := map[string]string{}
+	for _, p := range r.Participants {
+		if _, ok := got[p.Name]; ok {
+			t.Errorf("%s is listed twice", p.Name)
+		}
+		got[p.Name] = p.Status
+	}
+	want := map[string]string{"Fred": "sent", "Wilma": "unsent", "Barney": "maybe_sent", "Betty": "maybe_sent"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("participants = %v, want %v", got, want)
+	}
+}
+
+func TestGenerateConfigOutput(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	rootCmd.SetArgs([]string{"--generate-config", "-c", configPath, "--output", "json"})
+	stdout, _ := capture(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("--generate-config error = %v", err)
+		}
+	})
+	var got result
+	if err := json.Unmarshal(stdout, &got); err != nil || got.Status != "ok" || got.ExitCode != exitOK {
+		t.Errorf("--generate-config --output json wrote %q, %v", stdout, err)
+	}
+}
@@ -1,17 +1,31 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/dcmcand/go-secret-santa/package/calendar"
 	"github.com/dcmcand/go-secret-santa/package/conf"
 	csvLoader "github.com/dcmcand/go-secret-santa/package/csvparticipantloader"
 	fakeMailer "github.com/dcmcand/go-secret-santa/package/fakemailer"
-	"github.com/dcmcand/go-secret-santa/package/mgmailer"
+	"github.com/dcmcand/go-secret-santa/package/provider"
+	"github.com/dcmcand/go-secret-santa/package/relay"
+	"github.com/dcmcand/go-secret-santa/package/reveal"
 	"github.com/dcmcand/go-secret-santa/package/send"
 	"github.com/dcmcand/go-secret-santa/package/template"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -49,124 +63,626 @@ var rootCmd = &cobra.Command{
 
 		configPath, participantsPath, err := getConfigurationFiles(cmd)
 		if err != nil {
-			fmt.Printf("error getting configuration files: %v\n", err)
-			os.Exit(1)
+			fatal(exitConfig, "error getting configuration files", "err", err)
 		}
 
 		// Get generate config file flag
 		generateConfigFile, err := cmd.Flags().GetBool("generate-config")
 		if err != nil {
-			fmt.Printf("error retrieving generate-config flag\n")
-			os.Exit(1)
+			fatal(exitUsage, "error retrieving generate-config flag", "err", err)
 		}
 		// Get generate participants file flag
 		generateParticipantsFile, err := cmd.Flags().GetBool("generate-participants")
 		if err != nil {
-			fmt.Printf("error retrieving generate-participants flag: %v", err)
-			os.Exit(1)
+			fatal(exitUsage, "error retrieving generate-participants flag", "err", err)
 		}
 
-		// Generate config files if flags are set and exit the program
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			fatal(exitUsage, "error retrieving force flag", "err", err)
+		}
+
+		// Generate config files if flags are set and stop without sending
 		if generateConfigFile || generateParticipantsFile {
-			err := conf.GenerateConfigFiles(configPath, generateConfigFile, participantsPath, generateParticipantsFile)
-			if err != nil {
-				fmt.Printf("error generating config files: %v\n", err)
-				os.Exit(1)
+			err := conf.GenerateConfigFiles(configPath, generateConfigFile, participantsPath, generateParticipantsFile, force)
+			if errors.Is(err, conf.ErrFileExists) {
+				fatal(exitConfig, "error generating config files", "err", err)
 			}
-			os.Exit(0)
-		}
-		err = checkConfigFiles(configPath, participantsPath)
-		if err != nil {
-			fmt.Printf("error checking config files: %v\n", err)
-			os.Exit(1)
-		}
-		initConfig(configPath)
-
-		// Setup Email
-		subject := viper.GetString("email.subject")
-		if subject == "" {
-			subject = "Secret Santa Assignment"
-		}
-		domain := viper.GetString("email.domain")
-		if domain == "" {
-			fmt.Printf("Please set a domain to send email from")
-			os.Exit(1)
-		}
-		senderEmail := viper.GetString("email.sender.address")
-		if senderEmail == "" {
-			senderEmail = fmt.Sprintf("santa@%s", domain)
-		}
-		senderName := viper.GetString("email.sender.name")
-		if senderName == "" {
-			senderName = "Santa Claus"
-		}
-		emailDomain := viper.GetString("email.domain")
-		if emailDomain == "" {
-			fmt.Printf("Please set an email domain in the config file\n")
-			os.Exit(1)
-		}
-		var emailTemplate *send.Email
-		e, err := cmd.Flags().GetString("email-template")
-		if err != nil || e == "" {
-			emailTemplate, err = template.GetDefaultTemplate(subject, senderName, senderEmail)
 			if err != nil {
-				fmt.Printf("error getting default template: %v\n", err)
-				os.Exit(1)
+				fatal(exitError, "error generating config files", "err", err)
 			}
-		} else {
-			emailTemplate, err = template.GetTemplate(e, subject, senderName, senderEmail)
-			if err != nil {
-				fmt.Printf("error getting template: %v\n", err)
-				os.Exit(1)
+			return
+		}
+		runSend(cmd)
+	},
+}
+
+// runSend draws names and sends everyone their assignment. It is the root
+// command and the send subcommand.
+func runSend(cmd *cobra.Command) {
+	configPath, participantsPath, err := getConfigurationFiles(cmd)
+	if err != nil {
+		fatal(exitConfig, "error getting configuration files", "err", err)
+	}
+	err = checkConfigFiles(configPath, participantsPath)
+	if err != nil {
+		fatal(exitConfig, "error checking config files", "err", err)
+	}
+	initConfig(configPath)
+
+	wishlistDir, err := cmd.Flags().GetString("wishlists")
+	if err != nil {
+		fatal(exitUsage, "error retrieving wishlists flag", "err", err)
+	}
+	sender, _ := newSender(cmd, &csvLoader.Loader{WishlistDir: wishlistDir})
+	// Checked on their own so a bad participants file isn't taken for a
+	// draw that can't be made
+	if _, err := sender.ParticipantLoader.LoadParticipants(cmd.Context(), participantsPath); err != nil {
+		fatal(exitConfig, "invalid participants", "err", err)
+	}
+
+	// Send Emails
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if dryRun {
+		if sender.Draw, err = sender.NewDraw(cmd.Context(), participantsPath); err != nil {
+			fatal(exitCode(err), "error drawing names", "err", err)
+		}
+	} else {
+		// Save the draw before anything is sent, so a run that dies part
+		// way can be resumed rather than drawn again
+		if sender.Draw, sender.Journal, err = getDraw(cmd, sender, participantsPath); err != nil {
+			fatal(exitCode(err), err.Error())
+		}
+	}
+	output.setDraw(sender.Draw)
+	output.track(sender, dryRun)
+	err = sender.Send(cmd.Context(), participantsPath)
+	output.finishDelivery(sender, err)
+	if err != nil {
+		var report *send.DeliveryError
+		if errors.As(err, &report) {
+			args := []any{"sent", len(report.Sent), "total", len(report.Sent) + len(report.Failed) + len(report.Unsent) + len(report.MaybeSent), "err", report}
+			switch {
+			case len(report.MaybeSent) > 0:
+				args = append(args, "hint", "check with "+strings.Join(report.MaybeSent, ", ")+", then run again with --resume --resend-pending to send to them anyway")
+			case sender.Journal != nil:
+				args = append(args, "hint", "run again with --resume to send the rest")
 			}
+			fatal(exitCode(err), "not every assignment was sent", args...)
 		}
+		fatal(exitCode(err), "error sending emails", "err", err)
+	}
+}
+
+// newSender sets up the templates and notifiers from the flags and config
+// file. Dry runs print every message instead of sending it. The relay is
+// returned when it is turned on.
+func newSender(cmd *cobra.Command, loader send.ParticipantLoader) (*send.Sender, *relay.Relay) {
+	// Setup Email
+	subject := viper.GetString("email.subject")
+	if subject == "" {
+		subject = "Secret Santa Assignment"
+	}
+	domain := viper.GetString("email.domain")
+	if domain == "" {
+		fatal(exitConfig, "please set a domain to send email from")
+	}
+	senderEmail := viper.GetString("email.sender.address")
+	if senderEmail == "" {
+		senderEmail = fmt.Sprintf("santa@%s", domain)
+	}
+	senderName := viper.GetString("email.sender.name")
+	if senderName == "" {
+		senderName = "Santa Claus"
+	}
+	emailDomain := viper.GetString("email.domain")
+	if emailDomain == "" {
+		fatal(exitConfig, "please set an email domain in the config file")
+	}
+	channel, _ := cmd.Flags().GetString("channel")
+	if channel == "" {
+		channel = viper.GetString("delivery.channel")
+	}
+	if channel == "" {
+		channel = provider.Email
+	}
+	emailTemplate, err := getTemplate(cmd, channel, subject, senderName, senderEmail)
+	if err != nil {
+		fatal(exitConfig, "error getting template", "err", err)
+	}
 
-		// Send Emails
-		dryRun, err := cmd.Flags().GetBool("dry-run")
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		fatal(exitUsage, "error retrieving dry-run flag", "err", err)
+	}
+
+	giftsPerPerson, err := cmd.Flags().GetInt("gifts-per-person")
+	if err != nil || giftsPerPerson < 1 {
+		fatal(exitUsage, "gifts-per-person must be at least 1")
+	}
+
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	if !cmd.Flags().Changed("concurrency") && viper.IsSet("delivery.concurrency") {
+		concurrency = viper.GetInt("delivery.concurrency")
+	}
+	if concurrency < 1 {
+		fatal(exitUsage, "concurrency must be at least 1")
+	}
+	rateFlag, _ := cmd.Flags().GetString("rate")
+	if rateFlag == "" {
+		rateFlag = viper.GetString("delivery.rate")
+	}
+	rate, err := send.ParseRate(rateFlag)
+	if err != nil {
+		fatal(exitUsage, "error reading rate", "err", err)
+	}
+
+	sender := &send.Sender{
+		ParticipantLoader: loader,
+		EmailTemplate:     emailTemplate,
+		GiftsPerPerson:    giftsPerPerson,
+		Preferences:       getPreferences(),
+		Concurrency:       concurrency,
+		Rate:              rate,
+	}
+	// Participants can choose their own channels, so set up every channel
+	// that has a template and is configured.
+	sender.Templates = map[string]*send.Email{}
+	for _, c := range []string{provider.Email, "sms", "print"} {
+		if sender.Templates[c], err = getTemplate(cmd, c, subject, senderName, senderEmail); err != nil {
+			fatal(exitConfig, "error getting template", "channel", c, "err", err)
+		}
+	}
+	// Emails carry a calendar invite for the exchange when it has a date
+	invite, err := getInvite(emailDomain)
+	if err != nil {
+		fatal(exitConfig, "error creating calendar invite", "err", err)
+	}
+	if invite != nil {
+		emailTemplate.Attachments = append(emailTemplate.Attachments, *invite)
+		sender.Templates[provider.Email].Attachments = append(sender.Templates[provider.Email].Attachments, *invite)
+	}
+	sender.Notifiers = map[string]send.Notifier{}
+	var messages *relay.Relay
+	if dryRun {
+		sender.Notifier = &fakeMailer.Mailer{Channel: channel, Out: humanOutput()}
+		sender.Output = humanOutput()
+		// Keep the printed messages in one piece
+		sender.Concurrency = 1
+		for _, c := range provider.Channels() {
+			sender.Notifiers[c] = &fakeMailer.Mailer{Channel: c, Out: humanOutput()}
+		}
+	} else {
+		// The default channel is only needed for participants who don't
+		// list their own, which the sender checks before sending anything
+		sender.Notifier, err = getNotifier(channel, emailDomain)
 		if err != nil {
-			fmt.Printf("error retrieving dry-run flag\n")
-			os.Exit(1)
+			slog.Info("default channel not set up", "channel", channel, "err", err)
+			sender.Notifier = nil
+		}
+		for _, c := range provider.Channels() {
+			// Channels that aren't configured are reported if a participant
+			// picks one
+			if n, err := getNotifier(c, emailDomain); err == nil {
+				sender.Notifiers[c] = n
+			}
+		}
+		if useRelay, _ := cmd.Flags().GetBool("relay"); useRelay || viper.GetBool("relay.enabled") {
+			// Taken before the assignment notifiers are wrapped, so relayed
+			// messages are sent as they are
+			if messages, err = getRelay(sender, senderName, senderEmail); err != nil {
+				fatal(exitConfig, "error setting up the message relay", "err", err)
+			}
 		}
+		if revealLinks, _ := cmd.Flags().GetBool("reveal"); revealLinks || viper.GetBool("reveal.enabled") {
+			if err := useRevealLinks(cmd, sender, channel, subject, senderName, senderEmail); err != nil {
+				fatal(exitConfig, "error setting up reveal links", "err", err)
+			}
+		}
+		if messages != nil {
+			// Outermost, so the relay links are in the assignment behind a
+			// reveal link too
+			wrapNotifiers(sender, channel, func(_ string, next send.Notifier) send.Notifier {
+				return &relay.Notifier{Next: next, Relay: messages}
+			})
+		}
+	}
+	return sender, messages
+}
 
-		loader := csvLoader.Loader{}
-		sender := send.Sender{
-			ParticipantLoader: &loader,
-			EmailTemplate:     emailTemplate,
+// getRelay opens the relay passing messages between gifters and giftees,
+// kept in relay.dir in the config file. Messages are delivered through a
+// copy of sender as it is now.
+func getRelay(sender *send.Sender, senderName, senderEmail string) (*relay.Relay, error) {
+	baseURL := viper.GetString("relay.base_url")
+	if baseURL == "" {
+		baseURL = viper.GetString("server.base_url")
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("set relay.base_url in the config file to where secret-santa serve can be reached")
+	}
+	dir := viper.GetString("relay.dir")
+	if dir == "" {
+		dir = "./relay"
+	}
+	r, err := relay.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	if r.Template, err = template.GetDefaultRelayTemplate("A message about Secret Santa", senderName, senderEmail); err != nil {
+		return nil, fmt.Errorf("error getting relay template: %v", err)
+	}
+	base := *sender
+	base.Notifiers = maps.Clone(sender.Notifiers)
+	r.BaseURL = baseURL
+	r.Deliver = base.Message
+	return r, nil
+}
+
+// wrapNotifiers wraps every notifier but printed envelopes. wrap is given
+// the channel each notifier delivers on.
+func wrapNotifiers(sender *send.Sender, channel string, wrap func(string, send.Notifier) send.Notifier) {
+	if channel != "print" && sender.Notifier != nil {
+		sender.Notifier = wrap(channel, sender.Notifier)
+	}
+	for c, n := range sender.Notifiers {
+		if c != "print" {
+			sender.Notifiers[c] = wrap(c, n)
 		}
-		if dryRun {
-			sender.Emailer = &fakeMailer.Mailer{}
+	}
+}
+
+// useRevealLinks sends everyone a link to their assignment instead of the
+// assignment. Texts get a short message so they fit in one sms, and
+// printed envelopes are left as they are.
+func useRevealLinks(cmd *cobra.Command, sender *send.Sender, channel, subject, senderName, senderEmail string) error {
+	baseURL := viper.GetString("reveal.base_url")
+	if baseURL == "" {
+		baseURL = viper.GetString("server.base_url")
+	}
+	if baseURL == "" {
+		return fmt.Errorf("set reveal.base_url in the config file to where secret-santa serve can be reached")
+	}
+	expires := 30 * 24 * time.Hour
+	if s := viper.GetString("reveal.expires"); s != "" {
+		var err error
+		if days, ok := strings.CutSuffix(s, "d"); ok {
+			var n int
+			n, err = strconv.Atoi(days)
+			expires = time.Duration(n) * 24 * time.Hour
 		} else {
-			apiKey := viper.GetString("mailgun.apikey")
-			if apiKey == "" {
-				fmt.Printf("Please set a mailgun api key in the config file\n")
-				os.Exit(1)
-			}
+			expires, err = time.ParseDuration(s)
+		}
+		if err != nil || expires <= 0 {
+			return fmt.Errorf("invalid reveal.expires %q, use a number of days such as 30d or a duration such as 48h", s)
+		}
+	}
+	views := 3
+	if viper.IsSet("reveal.views") {
+		views = viper.GetInt("reveal.views")
+	}
+	if views < 1 {
+		return fmt.Errorf("reveal.views must be at least 1")
+	}
+	reveals, err := getRevealStore()
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.GetDefaultRevealTemplate(subject, senderName, senderEmail)
+	if path, _ := cmd.Flags().GetString("reveal-template"); path != "" {
+		tmpl, err = template.GetRevealTemplate(path, subject, senderName, senderEmail)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting reveal template: %v", err)
+	}
+	tmpl.Attachments = sender.Templates[provider.Email].Attachments
+	smsTmpl, err := template.GetDefaultSMSRevealTemplate(subject, senderName, senderEmail)
+	if err != nil {
+		return fmt.Errorf("error getting reveal template: %v", err)
+	}
+
+	wrapNotifiers(sender, channel, func(c string, next send.Notifier) send.Notifier {
+		n := &reveal.Notifier{Next: next, Store: reveals, BaseURL: baseURL, Expires: expires, MaxViews: views, Template: tmpl}
+		if c == "sms" {
+			n.Template = smsTmpl
+		}
+		return n
+	})
+	return nil
+}
+
+// getRevealStore opens the directory holding assignments sent as links,
+// reveal.dir in the config file.
+func getRevealStore() (*reveal.Store, error) {
+	dir := viper.GetString("reveal.dir")
+	if dir == "" {
+		dir = "./reveals"
+	}
+	return reveal.Open(dir)
+}
+
+func Execute() {
+	// Ctrl-C cancels the context every command runs with, so sending stops
+	// cleanly and reports who was and wasn't sent their assignment
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cmd, err := rootCmd.ExecuteContextC(ctx); err != nil {
+		usageFailed(cmd, err)
+	}
+}
 
-			sender.Emailer = mgmailer.NewMailgunEmailer(emailDomain, apiKey)
+func init() {
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := setupLogging(cmd, args); err != nil {
+			return err
 		}
-		err = sender.Send(participantsPath)
+		return setupOutput(cmd)
+	}
+	rootCmd.PersistentPostRun = writeOutput
+	rootCmd.PersistentFlags().String("log-level", "info", "how much to log: debug, info, warn or error")
+	rootCmd.PersistentFlags().String("log-format", "text", "how to write logs: text or json")
+	rootCmd.PersistentFlags().StringP("output", "o", "text", "what to write to stdout when the command finishes: text, or json for scripts, with logs and messages for people on stderr")
+	addSendFlags(rootCmd.Flags())
+	rootCmd.Flags().BoolP("generate-config", "", false, "generate a config file and exit without sending. An existing config file is only replaced with --force. Can be used with the --config flag to specify a path and name")
+	rootCmd.Flags().BoolP("generate-participants", "", false, "generate a participants file and exit without sending. An existing participants file is only replaced with --force. Can be used with the --participants flag to specify a path and name")
+	rootCmd.Flags().BoolP("force", "f", false, "overwrite existing files with --generate-config or --generate-participants")
+
+}
+
+// addSendFlags adds the flags of the root command and the send subcommand.
+func addSendFlags(flags *pflag.FlagSet) {
+	addDeliveryFlags(flags)
+	flags.StringP("participants", "p", "", "a csv file with participants (required)")
+	flags.StringP("wishlists", "w", "", "a directory of wishlist csv files named after each participant, e.g. Fred.csv")
+	flags.Bool("resume", false, "send the saved draw to everyone who hasn't been sent their assignment yet")
+	flags.Bool("resend-pending", false, "with --resume, also send to people whose message was on its way when the last run stopped, who may get it twice")
+	flags.Bool("redraw", false, "draw names again even though the saved draw has been sent to some people")
+}
+
+// addDeliveryFlags adds the flags for every command that draws names and
+// sends assignments.
+func addDeliveryFlags(flags *pflag.FlagSet) {
+	flags.BoolP("dry-run", "d", false, "dry-run will print a list rather than emailing people")
+	flags.StringP("email-template", "e", "", "a go template file for the email body")
+	flags.String("sms-template", "", "a go template file for text messages, keep it short to fit in one sms")
+	flags.String("envelope-template", "", "a go template file for the inside of printed envelopes")
+	flags.String("channel", "", "how assignments are delivered to participants without a Channels column: "+strings.Join(provider.Channels(), ", ")+" (default email, or delivery.channel from the config file)")
+	flags.IntP("gifts-per-person", "n", 1, "how many people each participant buys a gift for")
+	flags.Int("concurrency", 1, "how many messages are sent at once, or delivery.concurrency from the config file")
+	flags.String("rate", "", "the most messages sent in a period, e.g. 10/s or 100/m (default unlimited, or delivery.rate from the config file)")
+	flags.StringP("config", "c", "", "A configuration file for the application (required)")
+	flags.String("draw", "./draw.json", "where the draw is saved so it can be resumed or repaired, keep it secret as it holds everyone's assignment")
+	flags.String("journal", "./journal.jsonl", "where each delivery is recorded")
+	flags.Bool("reveal", false, "send a link to a page showing each assignment instead of the assignment, or reveal.enabled from the config file")
+	flags.String("reveal-template", "", "a go template file for the message with the reveal link")
+	flags.Bool("relay", false, "let gifters and giftees message each other through secret-santa serve without giving away who is who, or relay.enabled from the config file")
+}
+
+// getDraw opens the journal and returns the draw to send: the saved one
+// with --resume, otherwise a new draw that is saved first. A saved draw
+// that has been sent to anyone is only replaced with --redraw.
+func getDraw(cmd *cobra.Command, sender *send.Sender, participantsPath string) (*send.Draw, *send.Journal, error) {
+	drawPath, _ := cmd.Flags().GetString("draw")
+	journalPath, _ := cmd.Flags().GetString("journal")
+	resume, _ := cmd.Flags().GetBool("resume")
+	redraw, _ := cmd.Flags().GetBool("redraw")
+	if resume && redraw {
+		return nil, nil, fmt.Errorf("--resume and --redraw can't be used together")
+	}
+	if sender.ResendPending, _ = cmd.Flags().GetBool("resend-pending"); sender.ResendPending && !resume {
+		return nil, nil, fmt.Errorf("--resend-pending only works with --resume")
+	}
+	journal, err := send.OpenJournal(journalPath)
+	if err != nil {
+		return nil, nil, fileError{err}
+	}
+	if resume {
+		draw, err := send.LoadDraw(drawPath)
 		if err != nil {
-			fmt.Printf("error sending emails: %v\n", err)
-			os.Exit(1)
+			return nil, nil, fmt.Errorf("nothing to resume: %v", err)
 		}
-	},
+		slog.Info("resuming the saved draw", "draw", draw.ID, "created", draw.Created.Local().Format(time.DateTime),
+			"sent", len(journal.Deliveries(draw.ID)), "total", len(draw.Assignment.Pairs))
+		return draw, journal, nil
+	}
+	if saved, err := send.LoadDraw(drawPath); err == nil && !redraw {
+		if journal.Started(saved.ID) {
+			return nil, nil, fmt.Errorf("sending the draw in %s has already started, %d of %d people have it, use --resume to send the rest or --redraw to draw names again", drawPath, len(journal.Deliveries(saved.ID)), len(saved.Assignment.Pairs))
+		}
+	}
+	draw, err := sender.NewDraw(cmd.Context(), participantsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := draw.Save(drawPath); err != nil {
+		return nil, nil, fileError{err}
+	}
+	return draw, journal, nil
 }
 
-func Execute() {
-	err := rootCmd.Execute()
+// resendAssignments sends gifters their assignment from the sender's draw
+// again, revising them first so the messages aren't dropped as repeats.
+// An empty drawPath saves nothing, for dry runs.
+func resendAssignments(ctx context.Context, sender *send.Sender, drawPath, participantsPath string, gifters ...string) error {
+	assignment := sender.Draw.Assignment
+	for _, gifter := range gifters {
+		if _, ok := assignment.Giftees(gifter); !ok {
+			return fmt.Errorf("%s is not in the draw", gifter)
+		}
+		sender.Draw.Revise(gifter)
+	}
+	if err := saveDraw(sender.Draw, drawPath); err != nil {
+		return err
+	}
+	return sender.Resend(ctx, participantsPath, gifters...)
+}
+
+func saveDraw(draw *send.Draw, path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := draw.Save(path); err != nil {
+		return fileError{err}
+	}
+	return nil
+}
+
+// dropParticipant takes someone out of the sender's draw and saves it,
+// closes relay conversations for pairs that no longer exist, and sends the
+// people whose assignment changed their new one. An empty drawPath saves
+// nothing, for dry runs.
+func dropParticipant(ctx context.Context, sender *send.Sender, messages *relay.Relay, drawPath, participantsPath, name string) error {
+	participants, err := sender.ParticipantLoader.LoadParticipants(ctx, participantsPath)
 	if err != nil {
-		os.Exit(1)
+		return fmt.Errorf("%w: %v", send.ErrParticipants, err)
 	}
+	before := sender.Draw.Assignment
+	changed, err := sender.Draw.Drop(participants, name)
+	if err != nil {
+		return err
+	}
+	if err := saveDraw(sender.Draw, drawPath); err != nil {
+		return err
+	}
+	if err := closeConversations(messages, sender.Draw, before); err != nil {
+		return err
+	}
+	return sender.Resend(ctx, participantsPath, changed...)
 }
 
-func init() {
-	rootCmd.Flags().BoolP("dry-run", "d", false, "dry-run will print a list rather than emailing people")
-	rootCmd.Flags().StringP("participants", "p", "", "a csv file with participants (required)")
-	rootCmd.Flags().StringP("email-template", "e", "", "a go template file for the email body")
-	rootCmd.Flags().StringP("config", "c", "", "A configuration file for the application (required)")
-	rootCmd.Flags().BoolP("generate-config", "", false, "generate a config file. Note that this will overwrite an existing config file, and the application will not run. Can be used with the --config flag to specify a path and name")
-	rootCmd.Flags().BoolP("generate-participants", "", false, "generate a participants file. Note that this will overwrite an existing participants file of the same name, and the application will not run. Can be used with the --participants flag to specify a path and name")
+// addParticipant splices someone into the sender's draw and saves it,
+// closes relay conversations for pairs that no longer exist, and sends the
+// newcomer and the people now buying for them their assignment. An empty
+// drawPath saves nothing, for dry runs.
+func addParticipant(ctx context.Context, sender *send.Sender, messages *relay.Relay, drawPath, participantsPath, name string) error {
+	participants, err := sender.ParticipantLoader.LoadParticipants(ctx, participantsPath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", send.ErrParticipants, err)
+	}
+	before := sender.Draw.Assignment
+	changed, err := sender.Draw.Add(participants, name)
+	if err != nil {
+		return err
+	}
+	if err := saveDraw(sender.Draw, drawPath); err != nil {
+		return err
+	}
+	if err := closeConversations(messages, sender.Draw, before); err != nil {
+		return err
+	}
+	return sender.Resend(ctx, participantsPath, changed...)
+}
 
+// closeConversations closes the relay conversation of every pair in before
+// that isn't in the draw any more.
+func closeConversations(messages *relay.Relay, draw *send.Draw, before send.Assignment) error {
+	if messages == nil {
+		return nil
+	}
+	after := draw.Assignment
+	for _, pair := range before.Pairs {
+		giftees, _ := after.Giftees(pair.Gifter)
+		for _, giftee := range pair.Giftees {
+			if !slices.Contains(giftees, giftee) {
+				if err := messages.Close(draw.ID, pair.Gifter, giftee); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// getTemplate loads the template for a delivery channel. Texts have their
+// own short template so they fit in a single sms segment, and printed
+// envelopes have one that leaves out the greeting on the outside.
+func getTemplate(cmd *cobra.Command, channel, subject, senderName, senderEmail string) (*send.Email, error) {
+	flag, defaultTemplate := "email-template", template.GetDefaultTemplate
+	switch channel {
+	case "sms":
+		flag, defaultTemplate = "sms-template", template.GetDefaultSMSTemplate
+	case "print":
+		flag, defaultTemplate = "envelope-template", template.GetDefaultEnvelopeTemplate
+	}
+	path, err := cmd.Flags().GetString(flag)
+	if err != nil || path == "" {
+		return defaultTemplate(subject, senderName, senderEmail)
+	}
+	return template.GetTemplate(path, subject, senderName, senderEmail)
+}
+
+// getNotifier builds the provider for a delivery channel from its section
+// of the config file. The provider is named by <channel>.provider, e.g.
+// email.provider, and defaults to the first one registered for the channel.
+func getNotifier(channel, domain string) (send.Notifier, error) {
+	name := channel
+	if !slices.Contains(provider.Names(), name) {
+		name = viper.GetString(channel + ".provider")
+		if name == "" {
+			name = provider.DefaultProvider(channel)
+		}
+	}
+	return provider.New(name, provider.Config{
+		APIKey:      viper.GetString(name + ".apikey"),
+		Secret:      viper.GetString(name + ".secret"),
+		Region:      viper.GetString(name + ".region"),
+		BaseURL:     viper.GetString(name + ".baseurl"),
+		From:        viper.GetString(name + ".from"),
+		MaxSegments: viper.GetInt(name + ".segments"),
+		Format:      viper.GetString(name + ".format"),
+		Path:        viper.GetString(name + ".path"),
+		Domain:      domain,
+	})
+}
+
+// getInvite builds the calendar invite from the event section of the
+// config file. There is no invite when the event has no date.
+func getInvite(domain string) (*send.Attachment, error) {
+	date, err := calendar.ParseDate(viper.GetString("event.date"))
+	if err != nil || date.IsZero() {
+		return nil, err
+	}
+	deadline, err := calendar.ParseDate(viper.GetString("event.deadline"))
+	if err != nil {
+		return nil, err
+	}
+	// A reminder after the exchange would be no use to anyone
+	if deadline.After(date) {
+		return nil, fmt.Errorf("the gift deadline %s is after the event date %s", deadline.Format(time.DateOnly), date.Format(time.DateOnly))
+	}
+	event := calendar.Event{
+		Name:     viper.GetString("event.name"),
+		Location: viper.GetString("event.location"),
+		Date:     date,
+		Deadline: deadline,
+		Domain:   domain,
+	}
+	if event.Name == "" {
+		event.Name = "Secret Santa"
+	}
+	if budget := viper.GetString("event.budget"); budget != "" {
+		event.Description = "Gift budget: " + budget
+	}
+	invite := event.Attachment(time.Now())
+	return &invite, nil
+}
+
+// getPreferences builds the pairing score from the weights in the config
+// file. Preferences with no weight are left out, so with none configured
+// the draw is uniformly random.
+func getPreferences() send.Preferences {
+	var prefs send.Preferences
+	for key, preference := range map[string]func(int) send.Preference{
+		"cross_department": send.CrossDepartment,
+		"manager_report":   send.ManagerReport,
+		"same_country":     send.SameCountry,
+	} {
+		if weight := viper.GetInt("pairing.preferences." + key); weight != 0 {
+			prefs = append(prefs, preference(weight))
+		}
+	}
+	slices.SortFunc(prefs, func(a, b send.Preference) int { return strings.Compare(a.Name, b.Name) })
+	return prefs
 }
 
 func initConfig(configPath string) {
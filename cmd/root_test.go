@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	csvLoader "github.com/dcmcand/go-secret-santa/package/csvparticipantloader"
+	"github.com/dcmcand/go-secret-santa/package/send"
+	"github.com/dcmcand/go-secret-santa/package/template"
+	"github.com/dcmcand/go-secret-santa/package/twilionotifier"
+	"github.com/spf13/viper"
+)
+
+func TestGetInvite(t *testing.T) {
+	tests := []struct {
+		name       string
+		date       string
+		deadline   string
+		wantInvite bool
+		wantErr    string
+	}{
+		{name: "No date"},
+		{name: "Date", date: "2026-12-24", wantInvite: true},
+		{name: "Deadline before", date: "2026-12-24", deadline: "2026-12-20", wantInvite: true},
+		{name: "Deadline after", date: "2026-12-24", deadline: "2026-12-26", wantErr: "after the event date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("event.date", tt.date)
+			viper.Set("event.deadline", tt.deadline)
+			defer func() {
+				viper.Set("event.date", "")
+				viper.Set("event.deadline", "")
+			}()
+			invite, err := getInvite("bedrock.com")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("getInvite() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getInvite() error = %v", err)
+			}
+			if (invite != nil) != tt.wantInvite {
+				t.Errorf("getInvite() = %v, want an invite %v", invite, tt.wantInvite)
+			}
+		})
+	}
+}
+
+// recorder keeps the last message it was asked to send.
+type recorder struct{ body string }
+
+func (r *recorder) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	r.body = body
+	return "", err
+}
+
+func TestUseRevealLinks(t *testing.T) {
+	viper.Set("reveal.base_url", "https://secret-santa.bedrock.com")
+	viper.Set("reveal.dir", t.TempDir())
+	defer func() {
+		viper.Set("reveal.base_url", "")
+		viper.Set("reveal.dir", "")
+	}()
+	email, sms := &recorder{}, &recorder{}
+	emailTemplate, err := template.GetDefaultTemplate("Secret Santa", "Santa", "santa@bedrock.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &send.Sender{
+		Templates: map[string]*send.Email{"email": emailTemplate},
+		Notifiers: map[string]send.Notifier{"email": email, "sms": sms},
+	}
+	if err := useRevealLinks(rootCmd, sender, "email", "Secret Santa", "Santa", "santa@bedrock.com"); err != nil {
+		t.Fatalf("useRevealLinks() error = %v", err)
+	}
+
+	gifter := send.Participant{Name: "Bartholomew Flintstone"}
+	giftees := []send.Participant{{Name: "Wilma"}}
+	for channel, r := range map[string]*recorder{"email": email, "sms": sms} {
+		if _, err := sender.Notifiers[channel].Notify(context.Background(), gifter, giftees, emailTemplate); err != nil {
+			t.Fatalf("%s Notify() error = %v", channel, err)
+		}
+		if !strings.Contains(r.body, "https://secret-santa.bedrock.com/reveal/") || strings.Contains(r.body, "Wilma") {
+			t.Errorf("%s message %q should only have the reveal link", channel, r.body)
+		}
+	}
+	if n := twilionotifier.Segments(sms.body); n != 1 {
+		t.Errorf("the sms reveal message %q needs %d segments, want 1", sms.body, n)
+	}
+	if twilionotifier.Segments(email.body) == 1 {
+		t.Errorf("email got the short reveal message %q", email.body)
+	}
+}
+
+func TestGetDrawExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	participants := map[string]string{
+		"flintstones.csv": "Name,Email\nFred,fred@bedrock.com\nWilma,wilma@bedrock.com\nBarney,barney@bedrock.com\n",
+		"partners.csv":    "Name,Email,Partner\nFred,fred@bedrock.com,Wilma\nWilma,wilma@bedrock.com,Fred\n",
+	}
+	for name, content := range participants {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name         string
+		participants string
+		draw         string
+		journal      string
+		want         int
+	}{
+		{name: "Participants file missing", participants: "missing.csv", want: exitConfig},
+		{name: "Names can't be drawn", participants: "partners.csv", want: exitDraw},
+		{name: "Journal can't be read", participants: "flintstones.csv", journal: dir, want: exitError},
+		{name: "Draw can't be saved", participants: "flintstones.csv", draw: filepath.Join(dir, "missing", "draw.json"), want: exitError},
+	}
+	defer func() {
+		rootCmd.Flags().Set("draw", "./draw.json")
+		rootCmd.Flags().Set("journal", "./journal.jsonl")
+	}()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			draw, journal := tt.draw, tt.journal
+			if draw == "" {
+				draw = filepath.Join(t.TempDir(), "draw.json")
+			}
+			if journal == "" {
+				journal = filepath.Join(t.TempDir(), "journal.jsonl")
+			}
+			rootCmd.Flags().Set("draw", draw)
+			rootCmd.Flags().Set("journal", journal)
+			sender := &send.Sender{ParticipantLoader: &csvLoader.Loader{}}
+			_, _, err := getDraw(rootCmd, sender, filepath.Join(dir, tt.participants))
+			if err == nil {
+				t.Fatalf("getDraw() error = nil, want exit code %d", tt.want)
+			}
+			if got := exitCode(err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", err, got, tt.want)
+			}
+		})
+	}
+
+	// Someone with no channels and no default notifier is a config problem
+	sender := &send.Sender{ParticipantLoader: &csvLoader.Loader{}}
+	err := sender.Send(context.Background(), filepath.Join(dir, "flintstones.csv"))
+	if got := exitCode(err); got != exitConfig {
+		t.Errorf("exitCode(%v) = %d, want %d", err, got, exitConfig)
+	}
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var sendCmd = &cobra.Command{
+	Use:   "send",
+	Short: "Draw names and send everyone their assignment",
+	Long: `Draws names and sends everyone their assignment, like running secret-santa
+without a subcommand. The draw is saved and every delivery is journaled, so
+if sending stops part way, send --resume sends the rest without drawing again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSend(cmd)
+	},
+}
+
+func init() {
+	addSendFlags(sendCmd.Flags())
+	rootCmd.AddCommand(sendCmd)
+}
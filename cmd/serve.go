@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dcmcand/go-secret-santa/package/relay"
+	"github.com/dcmcand/go-secret-santa/package/send"
+	"github.com/dcmcand/go-secret-santa/package/server"
+	"github.com/dcmcand/go-secret-santa/package/store"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run a web server where participants sign up",
+	Long: `Runs a web server where participants sign up through an invite link instead
+of being listed in a csv file. Sign ups are kept in the store file. The
+organiser's page shows who has signed up, closes registration and draws names.
+
+The server also shows assignments sent as links with --reveal, whether they
+were sent from here or with the send command, and with --relay passes
+messages between gifters and giftees.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath, _ := cmd.Flags().GetString("config")
+		if configPath == "" {
+			configPath = "./config.yaml"
+		}
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			fatal(exitConfig, "config file does not exist", "path", configPath)
+		}
+		initConfig(configPath)
+
+		storePath, _ := cmd.Flags().GetString("store")
+		st, err := store.Open(storePath)
+		if err != nil {
+			fatal(exitError, "error opening the sign up store", "err", err)
+		}
+		addr, _ := cmd.Flags().GetString("addr")
+		baseURL, _ := cmd.Flags().GetString("base-url")
+		if baseURL == "" {
+			baseURL = viper.GetString("server.base_url")
+		}
+		if baseURL == "" {
+			baseURL = "http://localhost" + addr
+			if !strings.HasPrefix(addr, ":") {
+				baseURL = "http://" + addr
+			}
+		}
+		baseURL = strings.TrimSuffix(baseURL, "/")
+		adminToken := viper.GetString("server.admin_token")
+		if adminToken == "" {
+			token := make([]byte, 16)
+			rand.Read(token)
+			adminToken = hex.EncodeToString(token)
+		}
+
+		// Links to assignments point here unless configured otherwise
+		viper.SetDefault("reveal.base_url", baseURL)
+		viper.SetDefault("relay.base_url", baseURL)
+		reveals, err := getRevealStore()
+		if err != nil {
+			fatal(exitError, "error opening the reveal store", "err", err)
+		}
+
+		sender, messages := newSender(cmd, store.Loader{})
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		ex := &exchange{sender: sender, messages: messages, storePath: storePath}
+		if !dryRun {
+			if err := ex.open(cmd); err != nil {
+				fatal(exitCode(err), "error opening the saved draw", "err", err)
+			}
+		}
+		srv := &server.Server{
+			Store:      st,
+			AdminToken: adminToken,
+			BaseURL:    baseURL,
+			Reveals:    reveals,
+			Relay:      messages,
+			Draw:       ex.draw,
+		}
+		if !dryRun {
+			srv.Status, srv.Resend, srv.Drop = ex.status, ex.resend, ex.drop
+		}
+		// Both links let people in, so they are printed for the organiser
+		// rather than logged where anyone reading the logs would see them
+		fmt.Fprintf(humanOutput(), "Participants sign up here: %s/join/%s\nOrganise the exchange here: %s/admin/%s\n", baseURL, st.InviteToken(), baseURL, adminToken)
+		slog.Info("serving", "addr", addr)
+		if err := srv.ListenAndServe(cmd.Context(), addr); err != nil {
+			fatal(exitError, "error running server", "err", err)
+		}
+	},
+}
+
+// exchange draws names from the sign up store and keeps the draw and
+// journal where the send command would, so the organiser's page can follow
+// up on deliveries and repair the draw.
+type exchange struct {
+	sender    *send.Sender
+	messages  *relay.Relay
+	storePath string
+	drawPath  string
+
+	// mu keeps one draw, resend or repair going at a time, as they share
+	// the sender.
+	mu sync.Mutex
+}
+
+// open reads the journal and picks up the saved draw if it has already
+// been sent to anyone, so drawing again after a failure or restart only
+// sends the rest.
+func (e *exchange) open(cmd *cobra.Command) error {
+	e.drawPath, _ = cmd.Flags().GetString("draw")
+	journalPath, _ := cmd.Flags().GetString("journal")
+	journal, err := send.OpenJournal(journalPath)
+	if err != nil {
+		return fileError{err}
+	}
+	e.sender.Journal = journal
+	if saved, err := send.LoadDraw(e.drawPath); err == nil && journal.Started(saved.ID) {
+		e.sender.Draw = saved
+	}
+	return nil
+}
+
+// draw sends everyone their assignment, drawing names and saving them
+// first unless a saved draw is being resumed. Dry runs save nothing.
+func (e *exchange) draw(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sender.Journal != nil && e.sender.Draw == nil {
+		draw, err := e.sender.NewDraw(ctx, e.storePath)
+		if err != nil {
+			return err
+		}
+		if err := saveDraw(draw, e.drawPath); err != nil {
+			return err
+		}
+		e.sender.Draw = draw
+	}
+	return e.sender.Send(ctx, e.storePath)
+}
+
+func (e *exchange) status() (*send.Draw, []send.Delivery, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sender.Draw == nil {
+		return nil, nil, nil
+	}
+	draw := *e.sender.Draw
+	draw.Assignment.Pairs = slices.Clone(draw.Assignment.Pairs)
+	draw.Revisions = maps.Clone(draw.Revisions)
+	return &draw, e.sender.Journal.Deliveries(draw.ID), nil
+}
+
+func (e *exchange) resend(ctx context.Context, gifters ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sender.Draw == nil {
+		return fmt.Errorf("names haven't been drawn")
+	}
+	return resendAssignments(ctx, e.sender, e.drawPath, e.storePath, gifters...)
+}
+
+func (e *exchange) drop(ctx context.Context, name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sender.Draw == nil {
+		return fmt.Errorf("names haven't been drawn")
+	}
+	// Nothing from the draw has gone out, so names are drawn again without
+	// them
+	if !e.sender.Journal.Started(e.sender.Draw.ID) {
+		e.sender.Draw = nil
+		return nil
+	}
+	return dropParticipant(ctx, e.sender, e.messages, e.drawPath, e.storePath, name)
+}
+
+func init() {
+	addDeliveryFlags(serveCmd.Flags())
+	serveCmd.Flags().String("addr", ":8080", "the address to listen on")
+	serveCmd.Flags().String("base-url", "", "the url participants reach the server at, e.g. https://santa.example.com (default http://localhost:8080, or server.base_url from the config file)")
+	serveCmd.Flags().String("store", "./signups.json", "where sign ups are kept")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+	"github.com/dcmcand/go-secret-santa/package/store"
+	"github.com/dcmcand/go-secret-santa/package/template"
+)
+
+// flaky fails every message until it is fixed.
+type flaky struct {
+	fixed bool
+	sent  []string
+}
+
+func (f *flaky) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	if !f.fixed {
+		return "", errors.New("mail server down")
+	}
+	f.sent = append(f.sent, gifter.Name)
+	return "", nil
+}
+
+func TestExchange_DropBeforeSending(t *testing.T) {
+	tests := []struct {
+		name string
+		// stop sends nothing, otherwise every message fails and may have
+		// gone out
+		stop     bool
+		wantDraw bool
+	}{
+		{name: "Nothing sent forgets the draw", stop: true},
+		{name: "Maybe sent repairs the draw", wantDraw: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			storePath := filepath.Join(dir, "signups.json")
+			st, err := store.Open(storePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"Fred", "Wilma", "Barney", "Betty"} {
+				if err := st.Register(store.Registration{Name: name, Email: name + "@bedrock.com"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			journal, err := send.OpenJournal(filepath.Join(dir, "journal.jsonl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tmpl, err := template.GetDefaultTemplate("Secret Santa", "Santa", "santa@bedrock.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			notifier := &flaky{}
+			ex := &exchange{
+				sender:    &send.Sender{ParticipantLoader: store.Loader{}, Notifier: notifier, EmailTemplate: tmpl, Journal: journal},
+				storePath: storePath,
+				drawPath:  filepath.Join(dir, "draw.json"),
+			}
+
+			if tt.stop {
+				// As if the server stopped between saving the draw and sending
+				if ex.sender.Draw, err = ex.sender.NewDraw(context.Background(), storePath); err != nil {
+					t.Fatal(err)
+				}
+				if err := ex.sender.Draw.Save(ex.drawPath); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := ex.draw(context.Background()); err == nil {
+				t.Fatalf("draw() error = nil, want the send to fail")
+			}
+			notifier.fixed = true
+			if err := ex.drop(context.Background(), "Betty"); err != nil {
+				t.Fatalf("drop() error = %v", err)
+			}
+			if err := st.Remove("Betty"); err != nil {
+				t.Fatal(err)
+			}
+			if got := ex.sender.Draw != nil; got != tt.wantDraw {
+				t.Fatalf("draw kept after dropping Betty = %v, want %v", got, tt.wantDraw)
+			}
+			if tt.wantDraw {
+				saved, err := send.LoadDraw(ex.drawPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := saved.Assignment.Giftees("Betty"); ok {
+					t.Errorf("Betty is still in the saved draw")
+				}
+			}
+
+			// Drawing again works without Betty's sign up
+			ex.sender.ResendPending = true
+			if err := ex.draw(context.Background()); err != nil {
+				t.Fatalf("draw() after dropping Betty error = %v", err)
+			}
+			if _, ok := ex.sender.Draw.Assignment.Giftees("Betty"); ok || len(ex.sender.Draw.Assignment.Pairs) != 3 {
+				t.Errorf("draw after dropping Betty = %+v", ex.sender.Draw.Assignment)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	csvLoader "github.com/dcmcand/go-secret-santa/package/csvparticipantloader"
+
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config and participants files without sending anything",
+	Long: `Checks the config file, templates and participants file, that names can be
+drawn with every exclusion, and that everyone can be reached on a channel that
+is set up. Nothing is saved or sent, and nobody's assignment is shown. With
+--output json each participant is listed as valid or unreachable.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath, participantsPath, err := getConfigurationFiles(cmd)
+		if err != nil {
+			fatal(exitConfig, "error getting configuration files", "err", err)
+		}
+		if err := checkConfigFiles(configPath, participantsPath); err != nil {
+			fatal(exitConfig, "error checking config files", "err", err)
+		}
+		initConfig(configPath)
+
+		wishlistDir, _ := cmd.Flags().GetString("wishlists")
+		sender, _ := newSender(cmd, &csvLoader.Loader{WishlistDir: wishlistDir})
+		participants, err := sender.ParticipantLoader.LoadParticipants(cmd.Context(), participantsPath)
+		if err != nil {
+			fatal(exitConfig, "invalid participants", "err", err)
+		}
+		if _, err := sender.NewDraw(cmd.Context(), participantsPath); err != nil {
+			fatal(exitCode(err), "error drawing names", "err", err)
+		}
+
+		var unreachable []string
+		for _, name := range slices.Sorted(maps.Keys(participants)) {
+			p := participants[name]
+			status, problem := "valid", ""
+			if len(p.Channels) == 0 && sender.Notifier == nil {
+				problem = "lists no channels and the default channel isn't set up"
+			} else if len(p.Channels) > 0 && !slices.ContainsFunc(p.Channels, func(c string) bool { return sender.Notifiers[c] != nil }) {
+				problem = fmt.Sprintf("none of %s are set up", strings.Join(p.Channels, ", "))
+			}
+			if problem != "" {
+				status = "unreachable"
+				unreachable = append(unreachable, name+" "+problem)
+			}
+			if output != nil {
+				output.Participants = append(output.Participants, participantResult{Name: name, Status: status, Error: problem})
+			}
+		}
+		if len(unreachable) > 0 {
+			fatal(exitConfig, "not everyone can be sent their assignment", "err", strings.Join(unreachable, "; "))
+		}
+		slog.Info("names can be drawn and everyone can be sent their assignment", "participants", len(participants))
+	},
+}
+
+func init() {
+	addDeliveryFlags(validateCmd.Flags())
+	validateCmd.Flags().StringP("participants", "p", "", "a csv file with participants (required)")
+	validateCmd.Flags().StringP("wishlists", "w", "", "a directory of wishlist csv files named after each participant, e.g. Fred.csv")
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	participantsPath := filepath.Join(dir, "participants.csv")
+	files := map[string]string{
+		configPath:       "email:\n  provider: file\n  domain: bedrock.com\nfile:\n  format: eml\n  path: " + filepath.Join(dir, "out") + "\n",
+		participantsPath: "Name,Email,Partner\nFred,fred@bedrock.com,Wilma\nWilma,wilma@bedrock.com,Fred\nBarney,barney@bedrock.com,Betty\nBetty,betty@bedrock.com,Barney\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rootCmd.SetArgs([]string{"validate", "--output", "json", "-c", configPath, "-p", participantsPath})
+	stdout, _ := capture(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("validate error = %v", err)
+		}
+	})
+
+	var got result
+	if err := json.Unmarshal(stdout, &got); err != nil {
+		t.Fatalf("validate wrote %q, not a JSON result: %v", stdout, err)
+	}
+	if got.Command != "validate" || got.Status != "ok" || got.Draw != nil || len(got.Participants) != 4 {
+		t.Fatalf("validate result = %+v", got)
+	}
+	for _, p := range got.Participants {
+		if p.Status != "valid" {
+			t.Errorf("%s is %s: %s", p.Name, p.Status, p.Error)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "out")); len(entries) > 0 {
+		t.Errorf("validate wrote %d messages", len(entries))
+	}
+}
@@ -0,0 +1,134 @@
+package calendar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// Event is the gift exchange as it appears in a calendar.
+type Event struct {
+	Name        string
+	Location    string
+	Description string
+	// Date is the day of the exchange. The event lasts all day.
+	Date time.Time
+	// Deadline is the day gifts should be bought by. A reminder fires on
+	// the morning of that day, or a week before the exchange when it is
+	// zero.
+	Deadline time.Time
+	// Domain makes the event's UID globally unique, e.g. the sending
+	// domain.
+	Domain string
+}
+
+// Attachment returns the event as an invite.ics attachment.
+func (e Event) Attachment(now time.Time) send.Attachment {
+	return send.Attachment{
+		Filename:    "invite.ics",
+		ContentType: "text/calendar; charset=utf-8; method=PUBLISH",
+		Data:        e.ICS(now),
+	}
+}
+
+// ICS encodes the event as an RFC 5545 calendar. now is the time stamp the
+// event was created at.
+func (e Event) ICS(now time.Time) []byte {
+	var b bytes.Buffer
+	line := func(name, value string) { b.WriteString(fold(name + ":" + value)) }
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//go-secret-santa//EN")
+	line("METHOD", "PUBLISH")
+	line("BEGIN", "VEVENT")
+	line("UID", e.uid())
+	line("DTSTAMP", now.UTC().Format("20060102T150405Z"))
+	line("DTSTART;VALUE=DATE", e.Date.Format("20060102"))
+	line("DTEND;VALUE=DATE", e.Date.AddDate(0, 0, 1).Format("20060102"))
+	line("SUMMARY", escape(e.Name))
+	if e.Location != "" {
+		line("LOCATION", escape(e.Location))
+	}
+	if e.Description != "" {
+		line("DESCRIPTION", escape(e.Description))
+	}
+	line("TRANSP", "TRANSPARENT")
+
+	line("BEGIN", "VALARM")
+	line("ACTION", "DISPLAY")
+	line("DESCRIPTION", escape("Buy your gift for "+e.Name))
+	line("TRIGGER;RELATED=START", e.reminder())
+	line("END", "VALARM")
+	line("END", "VEVENT")
+	line("END", "VCALENDAR")
+	return b.Bytes()
+}
+
+// reminder is the alarm's offset from the start of the exchange: 9am on
+// the deadline, or a week before. All day events start at midnight in the
+// reader's own time zone, so an offset keeps the reminder in the morning
+// wherever they are.
+func (e Event) reminder() string {
+	if e.Deadline.IsZero() {
+		return "-P7D"
+	}
+	days := int(e.Date.Sub(e.Deadline).Hours() / 24)
+	hours := 9 - 24*days
+	if hours < 0 {
+		return fmt.Sprintf("-P%dDT%dH", -hours/24, -hours%24)
+	}
+	return fmt.Sprintf("PT%dH", hours)
+}
+
+// uid stays the same for the same exchange, so sending again updates the
+// event in people's calendars rather than adding a second one.
+func (e Event) uid() string {
+	sum := sha256.Sum256([]byte(e.Name + "\x00" + e.Date.Format(time.DateOnly) + "\x00" + e.Location))
+	domain := e.Domain
+	if domain == "" {
+		domain = "go-secret-santa"
+	}
+	return hex.EncodeToString(sum[:8]) + "@" + domain
+}
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold ends a content line with CRLF, folding it so no line is longer than
+// 75 octets without splitting a character.
+func fold(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// ParseDate reads a YYYY-MM-DD date from the config file. An empty date is
+// the zero time.
+func ParseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD date", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,54 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvent_ICS(t *testing.T) {
+	date := time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		deadline time.Time
+		want     string
+	}{
+		{name: "A week before by default", want: "TRIGGER;RELATED=START:-P7D\r\n"},
+		{name: "9am on the deadline", deadline: date.AddDate(0, 0, -7), want: "TRIGGER;RELATED=START:-P6DT15H\r\n"},
+		{name: "Deadline on the day", deadline: date, want: "TRIGGER;RELATED=START:PT9H\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Event{Name: "Secret Santa", Location: "Fred's house, Bedrock", Date: date, Deadline: tt.deadline, Domain: "bedrock.org"}
+			got := string(e.ICS(now))
+			for _, want := range []string{
+				"BEGIN:VCALENDAR\r\n",
+				"DTSTAMP:20241101T120000Z\r\n",
+				"DTSTART;VALUE=DATE:20241220\r\n",
+				"DTEND;VALUE=DATE:20241221\r\n",
+				"LOCATION:Fred's house\\, Bedrock\r\n",
+				"@bedrock.org\r\n",
+				tt.want,
+				"END:VCALENDAR\r\n",
+			} {
+				if !strings.Contains(got, want) {
+					t.Errorf("ICS() = %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFold(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("é", 100)
+	got := fold(line)
+	for _, l := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line %q is %d octets, want at most 75", l, len(l))
+		}
+	}
+	if unfolded := strings.ReplaceAll(got, "\r\n ", ""); unfolded != line+"\r\n" {
+		t.Errorf("fold() doesn't unfold back to the line: %q", unfolded)
+	}
+}
@@ -2,13 +2,65 @@ package conf
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/dcmcand/go-secret-santa/package/filemailer"
+	"github.com/dcmcand/go-secret-santa/package/provider"
+	"github.com/dcmcand/go-secret-santa/package/send"
 	"gopkg.in/yaml.v3"
 )
 
-func GenerateConfigFiles(configPath string, generateConfig bool, participantsPath string, generateParticipants bool) error {
+// ErrFileExists is returned when a file would be overwritten without force.
+var ErrFileExists = errors.New("file already exists")
+
+// Providers lists the email providers that can be written to a config file.
+var Providers = provider.EmailProviders()
+
+// Settings holds everything written to a config file.
+type Settings struct {
+	Provider string
+	APIKey   string
+	Secret   string
+	Region   string
+	// Format and Path say where the file provider writes messages.
+	Format        string
+	Path          string
+	Domain        string
+	Subject       string
+	SenderName    string
+	SenderAddress string
+	Event         Event
+}
+
+// Event describes the gift exchange itself.
+type Event struct {
+	Name string
+	Date string
+	// Deadline is the date gifts should be bought by.
+	Deadline string
+	Location string
+	Budget   string
+}
+
+// GenerateConfigFiles writes sample config and participants files. Like
+// WriteConfig and WriteParticipants it refuses to replace existing files
+// unless force is set, and checks both before writing either.
+func GenerateConfigFiles(configPath string, generateConfig bool, participantsPath string, generateParticipants bool, force bool) error {
+	if generateConfig {
+		if err := checkOverwrite(configPath, force); err != nil {
+			return err
+		}
+	}
+	if generateParticipants {
+		if err := checkOverwrite(participantsPath, force); err != nil {
+			return err
+		}
+	}
+
 	if generateConfig {
 		err := generateConfigFile(configPath)
 		if err != nil {
@@ -26,92 +78,81 @@ func GenerateConfigFiles(configPath string, generateConfig bool, participantsPat
 	return nil
 }
 
+// WriteConfig writes the settings to path. It refuses to replace an
+// existing file unless force is set.
+func WriteConfig(path string, s Settings, force bool) error {
+	if err := checkOverwrite(path, force); err != nil {
+		return err
+	}
+	return writeConfigFile(path, s)
+}
+
+// WriteParticipants writes the participants to a csv file at path. It
+// refuses to replace an existing file unless force is set.
+func WriteParticipants(path string, participants []send.Participant, force bool) error {
+	if err := checkOverwrite(path, force); err != nil {
+		return err
+	}
+	return writeParticipantsFile(path, participants)
+}
+
+func checkOverwrite(path string, force bool) error {
+	if force {
+		return nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s: %w, use --force to overwrite it", path, ErrFileExists)
+	}
+	return nil
+}
+
+func defaultSettings() Settings {
+	return Settings{
+		Provider:   "mailgun",
+		APIKey:     "abc123",
+		Subject:    "Secret Santa",
+		SenderName: "Santa Claus",
+	}
+}
+
 func generateConfigFile(path string) error {
+	return writeConfigFile(path, defaultSettings())
+}
+
+func writeConfigFile(path string, s Settings) error {
+	provider := s.Provider
+	if provider == "" {
+		provider = "mailgun"
+	}
 	config := &yaml.Node{
 		Kind: yaml.DocumentNode,
 		Content: []*yaml.Node{
-			{
-				Kind: yaml.MappingNode,
-				Content: []*yaml.Node{
-					{
-						Kind:  yaml.ScalarNode,
-						Value: "mailgun",
-					},
-					{
-						Kind: yaml.MappingNode,
-						Content: []*yaml.Node{
-							{
-								Kind:  yaml.ScalarNode,
-								Value: "apikey",
-							},
-							{
-								Kind:        yaml.ScalarNode,
-								Style:       yaml.DoubleQuotedStyle,
-								Value:       "abc123",
-								LineComment: "# This is the mailgun api key",
-							},
-						},
-					},
-					{
-						Kind:  yaml.ScalarNode,
-						Value: "email",
-					},
-					{
-						Kind: yaml.MappingNode,
-						Content: []*yaml.Node{
-
-							{
-								Kind:  yaml.ScalarNode,
-								Value: "subject",
-							},
-							{
-								Kind:        yaml.ScalarNode,
-								Style:       yaml.DoubleQuotedStyle,
-								Value:       "Secret Santa",
-								LineComment: "# This is the subject of the secret santa email",
-							},
-							{
-								Kind:  yaml.ScalarNode,
-								Value: "address",
-							},
-							{
-								Kind:        yaml.ScalarNode,
-								Style:       yaml.DoubleQuotedStyle,
-								Value:       "santa",
-								LineComment: "# This along with the domain is used to create the email address of the sender",
-							},
-							{
-								Kind:  yaml.ScalarNode,
-								Value: "domain",
-							},
-							{
-								Kind:        yaml.ScalarNode,
-								Style:       yaml.DoubleQuotedStyle,
-								LineComment: "# This is the domain of the email",
-							},
-							{
-								Kind:  yaml.ScalarNode,
-								Value: "sender",
-							},
-							{
-								Kind: yaml.MappingNode,
-								Content: []*yaml.Node{
-									{
-										Kind:  yaml.ScalarNode,
-										Value: "name",
-									},
-									{
-										Kind:        yaml.ScalarNode,
-										Style:       yaml.DoubleQuotedStyle,
-										Value:       "Santa Claus",
-										LineComment: "# This is the name of the sender for use in the email body",
-									},
-								},
-							},
-						},
-					},
-				},
-			},
+			mapping(
+				key(provider), providerNode(provider, s),
+				key("email"), mapping(
+					key("provider"), value(provider, "This is the service used to send email"),
+					key("subject"), value(s.Subject, "This is the subject of the secret santa email"),
+					key("domain"), value(s.Domain, "This is the domain of the email"),
+					key("sender"), mapping(
+						key("name"), value(s.SenderName, "This is the name of the sender for use in the email body"),
+						key("address"), value(s.SenderAddress, "This is the email address of the sender, santa@ the domain when empty"),
+					),
+				),
+				key("event"), mapping(
+					key("name"), value(s.Event.Name, "This is the name of the gift exchange"),
+					key("date"), value(s.Event.Date, "This is the date of the exchange (YYYY-MM-DD)"),
+					key("deadline"), value(s.Event.Deadline, "This is the date gifts should be bought by (YYYY-MM-DD), a week before the exchange when empty"),
+					key("location"), value(s.Event.Location, "This is where the exchange takes place"),
+					key("budget"), value(s.Event.Budget, "This is the suggested spend per gift"),
+				),
+				key("pairing"), mapping(
+					key("preferences"), mapping(
+						key("cross_department"), number(0, "Points for pairing people from different departments"),
+						key("manager_report"), number(0, "Points for pairing a manager with a direct report, negative to avoid it"),
+						key("same_country"), number(0, "Points for pairing people who ship to the same country"),
+					),
+				),
+			),
 		},
 	}
 	y, err := yaml.Marshal(config)
@@ -125,20 +166,79 @@ func generateConfigFile(path string) error {
 	return nil
 }
 
+// providerNode writes the settings a provider reads from its own section.
+func providerNode(provider string, s Settings) *yaml.Node {
+	if provider == "file" {
+		return mapping(
+			key("format"), value(orDefault(s.Format, filemailer.EML), "One of "+strings.Join(filemailer.Formats, ", ")),
+			key("path"), value(orDefault(s.Path, "./assignments"), "The directory, mbox file or Maildir messages are written to"),
+		)
+	}
+	if provider == "ses" {
+		return mapping(
+			key("apikey"), value(s.APIKey, "This is the access key id"),
+			key("secret"), value(s.Secret, "This is the secret access key"),
+			key("region"), value(s.Region, "This is the region email is sent from"),
+			key("baseurl"), value("", "Overrides the api endpoint, leave empty for the region's endpoint"),
+		)
+	}
+	return mapping(
+		key("apikey"), value(s.APIKey, "This is the "+provider+" api key"),
+		key("baseurl"), value("", "Overrides the api endpoint, leave empty for the default"),
+	)
+}
+
+func mapping(content ...*yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Content: content}
+}
+
+func key(k string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: k}
+}
+
+func value(v, comment string) *yaml.Node {
+	return &yaml.Node{
+		Kind:        yaml.ScalarNode,
+		Style:       yaml.DoubleQuotedStyle,
+		Value:       v,
+		LineComment: "# " + comment,
+	}
+}
+
+func number(v int, comment string) *yaml.Node {
+	return &yaml.Node{
+		Kind:        yaml.ScalarNode,
+		Tag:         "!!int",
+		Value:       strconv.Itoa(v),
+		LineComment: "# " + comment,
+	}
+}
+
 func generateParticipantsFile(path string) error {
+	return writeParticipantsFile(path, []send.Participant{
+		{Name: "Barney", Email: "barney@bedrock.com", Partner: "Betty", Interests: []string{"Bowling", "Jokes", "Movies"}, Wishlist: []send.WishlistItem{
+			{Name: "Bowling ball", URL: "https://example.com/bowling-ball", Price: "$40", Priority: 1},
+			{Name: "Joke book", Price: "$10", Priority: 2},
+		}},
+		{Name: "Fred", Email: "fred@bedrock.com", Partner: "Wilma", Interests: []string{"Bowling", "Dinosaurs", "Golf"}},
+		{Name: "Wilma", Email: "wilma@bedrock.com", Partner: "Fred", Interests: []string{"Cooking", "Gardening", "Shopping"}},
+		{Name: "Betty", Email: "betty@bedrock.com", Partner: "Barney", Interests: []string{"Reading", "Music", "Crafts"}},
+		{Name: "Pebbles", Email: "pebbles@bedrock.com", Interests: []string{"Exploring", "Drawing", "Sports"}},
+		{Name: "BamBam", Email: "bambam@bedrock.com", Interests: []string{"Rock Music", "Cave Painting", "Athletics"}},
+	})
+}
+
+func writeParticipantsFile(path string, participants []send.Participant) error {
 	f, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("error creating participants file at %s: %v", path, err)
 	}
 	defer f.Close()
 	content := [][]string{
-		{"Name", "Email", "Partner", "Interests"},
-		{"Barney", "barney@bedrock.com", "Betty", "Bowling, Jokes, Movies"},
-		{"Fred", "fred@bedrock.com", "Wilma", "Bowling, Dinosaurs, Golf"},
-		{"Wilma", "wilma@bedrock.com", "Fred", "Cooking, Gardening, Shopping"},
-		{"Betty", "betty@bedrock.com", "Barney", "Reading, Music, Crafts"},
-		{"Pebbles", "pebbles@bedrock.com", "", "Exploring, Drawing, Sports"},
-		{"BamBam", "bambam@bedrock.com", "", "Rock Music, Cave Painting, Athletics"},
+		{"Name", "Email", "Partner", "Interests", "Wishlist"},
+	}
+	for _, p := range participants {
+		content = append(content, []string{p.Name, p.Email, p.Partner, strings.Join(p.Interests, ", "), send.FormatWishlist(p.Wishlist)})
 	}
 	writer := csv.NewWriter(f)
 	defer writer.Flush()
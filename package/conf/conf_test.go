@@ -0,0 +1,89 @@
+package conf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateConfigFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing files are written before generating.
+		existing             []string
+		generateConfig       bool
+		generateParticipants bool
+		force                bool
+		wantErr              error
+		// unchanged files must still hold what was there before.
+		unchanged []string
+		// absent files must not have been written.
+		absent []string
+	}{
+		{
+			name:                 "New files",
+			generateConfig:       true,
+			generateParticipants: true,
+		},
+		{
+			name:           "Existing config kept",
+			existing:       []string{"config.yaml"},
+			generateConfig: true,
+			wantErr:        ErrFileExists,
+			unchanged:      []string{"config.yaml"},
+		},
+		{
+			name:                 "Nothing written when one file exists",
+			existing:             []string{"participants.csv"},
+			generateConfig:       true,
+			generateParticipants: true,
+			wantErr:              ErrFileExists,
+			unchanged:            []string{"participants.csv"},
+			absent:               []string{"config.yaml"},
+		},
+		{
+			name:                 "Existing files replaced with force",
+			existing:             []string{"config.yaml", "participants.csv"},
+			generateConfig:       true,
+			generateParticipants: true,
+			force:                true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "config.yaml")
+			participantsPath := filepath.Join(dir, "participants.csv")
+			for _, name := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("mine"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := GenerateConfigFiles(configPath, tt.generateConfig, participantsPath, tt.generateParticipants, tt.force)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GenerateConfigFiles() error = %v, want %v", err, tt.wantErr)
+			}
+			for _, name := range tt.unchanged {
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil || string(b) != "mine" {
+					t.Errorf("%s was replaced: %q, %v", name, b, err)
+				}
+			}
+			for _, name := range tt.absent {
+				if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+					t.Errorf("%s was written", name)
+				}
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			for path, generated := range map[string]bool{configPath: tt.generateConfig, participantsPath: tt.generateParticipants} {
+				b, err := os.ReadFile(path)
+				if generated && (err != nil || string(b) == "mine") {
+					t.Errorf("%s wasn't generated: %q, %v", path, b, err)
+				}
+			}
+		})
+	}
+}
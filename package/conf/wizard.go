@@ -0,0 +1,328 @@
+package conf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/filemailer"
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// Wizard asks the organizer for settings and participants one question at a
+// time. Defaults are taken from the settings it is started with, so values
+// already given as flags only need to be confirmed.
+type Wizard struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func NewWizard(in io.Reader, out io.Writer) *Wizard {
+	return &Wizard{
+		scanner: bufio.NewScanner(in),
+		out:     out,
+	}
+}
+
+// AskSettings prompts for every setting, offering the current value as the
+// default.
+func (w *Wizard) AskSettings(s Settings) (Settings, error) {
+	var err error
+	s.Provider, err = w.askValid("Email provider ("+strings.Join(Providers, ", ")+")", orDefault(s.Provider, "mailgun"), ValidateProvider)
+	if err != nil {
+		return s, err
+	}
+	if s.Provider == "ses" {
+		s.APIKey, err = w.askRequired("ses access key id", s.APIKey)
+		if err != nil {
+			return s, err
+		}
+		s.Secret, err = w.askRequired("ses secret access key", s.Secret)
+		if err != nil {
+			return s, err
+		}
+		s.Region, err = w.ask("ses region", orDefault(s.Region, "us-east-1"))
+		if err != nil {
+			return s, err
+		}
+	} else if s.Provider == "file" {
+		s.Format, err = w.askValid("File format ("+strings.Join(filemailer.Formats, ", ")+")", orDefault(s.Format, filemailer.EML), ValidateFormat)
+		if err != nil {
+			return s, err
+		}
+		s.Path, err = w.ask("Write messages to", orDefault(s.Path, "./assignments"))
+		if err != nil {
+			return s, err
+		}
+	} else {
+		s.APIKey, err = w.askRequired(s.Provider+" API key", s.APIKey)
+		if err != nil {
+			return s, err
+		}
+	}
+	s.Domain, err = w.askRequired("Sending domain (e.g. example.com)", s.Domain)
+	if err != nil {
+		return s, err
+	}
+	s.Subject, err = w.ask("Email subject", orDefault(s.Subject, "Secret Santa Assignment"))
+	if err != nil {
+		return s, err
+	}
+	s.SenderName, err = w.ask("Sender name", orDefault(s.SenderName, "Santa Claus"))
+	if err != nil {
+		return s, err
+	}
+	s.SenderAddress, err = w.askValid("Sender address", orDefault(s.SenderAddress, "santa@"+s.Domain), ValidateEmail)
+	if err != nil {
+		return s, err
+	}
+	s.Event.Name, err = w.ask("Event name", orDefault(s.Event.Name, "Secret Santa"))
+	if err != nil {
+		return s, err
+	}
+	s.Event.Date, err = w.askValid("Event date (YYYY-MM-DD, optional)", s.Event.Date, optional(ValidateDate))
+	if err != nil {
+		return s, err
+	}
+	s.Event.Deadline, err = w.askValid("Buy gifts by (YYYY-MM-DD, optional)", s.Event.Deadline, optional(ValidateDate))
+	if err != nil {
+		return s, err
+	}
+	s.Event.Location, err = w.ask("Event location (optional)", s.Event.Location)
+	if err != nil {
+		return s, err
+	}
+	s.Event.Budget, err = w.ask("Gift budget (optional)", s.Event.Budget)
+	if err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// AskParticipants adds participants until an empty name is entered.
+// Emails are checked as they are typed and duplicates are rejected.
+func (w *Wizard) AskParticipants(participants []send.Participant) ([]send.Participant, error) {
+	fmt.Fprintln(w.out, "Add participants, leave the name empty to finish.")
+	for {
+		name, err := w.ask("Name", "")
+		if err != nil {
+			return participants, err
+		}
+		if name == "" {
+			return participants, nil
+		}
+		if slices.ContainsFunc(participants, func(p send.Participant) bool { return p.Name == name }) {
+			fmt.Fprintf(w.out, "%s has already been added\n", name)
+			continue
+		}
+		email, err := w.askValid("Email", "", func(email string) error {
+			if err := ValidateEmail(email); err != nil {
+				return err
+			}
+			if slices.ContainsFunc(participants, func(p send.Participant) bool { return strings.EqualFold(p.Email, email) }) {
+				return fmt.Errorf("%s is already used by another participant", email)
+			}
+			return nil
+		})
+		if err != nil {
+			return participants, err
+		}
+		partner, err := w.ask("Partner (optional)", "")
+		if err != nil {
+			return participants, err
+		}
+		interests, err := w.ask("Interests (comma separated)", "")
+		if err != nil {
+			return participants, err
+		}
+		var wishlist []send.WishlistItem
+		_, err = w.askValid("Wishlist as Item|URL|Price|Priority separated by ; (optional)", "", func(s string) error {
+			wishlist, err = send.ParseWishlist(s)
+			return err
+		})
+		if err != nil {
+			return participants, err
+		}
+		participants = append(participants, send.Participant{
+			Name:      name,
+			Email:     email,
+			Partner:   partner,
+			Interests: send.SplitList(interests, ","),
+			Wishlist:  wishlist,
+		})
+	}
+}
+
+func (w *Wizard) ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+	if !w.scanner.Scan() {
+		if err := w.scanner.Err(); err != nil {
+			return "", fmt.Errorf("error reading answer: %v", err)
+		}
+		return "", io.ErrUnexpectedEOF
+	}
+	answer := strings.TrimSpace(w.scanner.Text())
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+func (w *Wizard) askRequired(question, def string) (string, error) {
+	return w.askValid(question, def, func(s string) error {
+		if s == "" {
+			return fmt.Errorf("a value is required")
+		}
+		return nil
+	})
+}
+
+// askValid repeats the question until the answer passes validate.
+func (w *Wizard) askValid(question, def string, validate func(string) error) (string, error) {
+	for {
+		answer, err := w.ask(question, def)
+		if err != nil {
+			return "", err
+		}
+		if err := validate(answer); err != nil {
+			fmt.Fprintf(w.out, "invalid answer: %v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// ValidateSettings checks settings supplied without the wizard.
+func ValidateSettings(s Settings) error {
+	if err := ValidateProvider(s.Provider); err != nil {
+		return err
+	}
+	if s.Provider == "file" {
+		if err := ValidateFormat(orDefault(s.Format, filemailer.EML)); err != nil {
+			return err
+		}
+	} else if s.APIKey == "" {
+		return fmt.Errorf("an api key is required")
+	}
+	if s.Provider == "ses" && s.Secret == "" {
+		return fmt.Errorf("ses needs a secret access key")
+	}
+	if s.Domain == "" {
+		return fmt.Errorf("a sending domain is required")
+	}
+	if err := ValidateEmail(s.SenderAddress); err != nil {
+		return fmt.Errorf("invalid sender address: %v", err)
+	}
+	if err := optional(ValidateDate)(s.Event.Date); err != nil {
+		return fmt.Errorf("invalid event date: %v", err)
+	}
+	if err := optional(ValidateDate)(s.Event.Deadline); err != nil {
+		return fmt.Errorf("invalid gift deadline: %v", err)
+	}
+	// Dates in the form YYYY-MM-DD sort as strings
+	if s.Event.Date != "" && s.Event.Deadline > s.Event.Date {
+		return fmt.Errorf("the gift deadline %s is after the event date %s", s.Event.Deadline, s.Event.Date)
+	}
+	return nil
+}
+
+// ValidateParticipants checks participants supplied without the wizard.
+func ValidateParticipants(participants []send.Participant) error {
+	names := make(map[string]struct{})
+	emails := make(map[string]struct{})
+	for _, p := range participants {
+		if p.Name == "" {
+			return fmt.Errorf("participant with email %s has no name", p.Email)
+		}
+		if _, ok := names[p.Name]; ok {
+			return fmt.Errorf("%s is listed more than once", p.Name)
+		}
+		if err := ValidateEmail(p.Email); err != nil {
+			return fmt.Errorf("invalid email for %s: %v", p.Name, err)
+		}
+		if _, ok := emails[strings.ToLower(p.Email)]; ok {
+			return fmt.Errorf("%s is used by more than one participant", p.Email)
+		}
+		names[p.Name] = struct{}{}
+		emails[strings.ToLower(p.Email)] = struct{}{}
+	}
+	return nil
+}
+
+// ValidateFormat checks a file provider format.
+func ValidateFormat(format string) error {
+	if !slices.Contains(filemailer.Formats, strings.ToLower(format)) {
+		return fmt.Errorf("unsupported format %q, choose one of %s", format, strings.Join(filemailer.Formats, ", "))
+	}
+	return nil
+}
+
+func ValidateProvider(provider string) error {
+	if !slices.Contains(Providers, provider) {
+		return fmt.Errorf("unsupported provider %q, choose one of %s", provider, strings.Join(Providers, ", "))
+	}
+	return nil
+}
+
+// ValidateEmail accepts a bare address such as santa@example.com.
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid email address", email)
+	}
+	if addr.Address != email {
+		return fmt.Errorf("%q should be a bare address like name@example.com", email)
+	}
+	return nil
+}
+
+func ValidateDate(date string) error {
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return fmt.Errorf("%q is not a date in the form YYYY-MM-DD", date)
+	}
+	return nil
+}
+
+// ParseParticipant reads a participant from a "Name,Email,Partner,Interests"
+// string as used by the --participant flag. Interests may contain commas.
+func ParseParticipant(s string) (send.Participant, error) {
+	fields := strings.SplitN(s, ",", 4)
+	if len(fields) < 2 {
+		return send.Participant{}, fmt.Errorf("participant %q should look like Name,Email[,Partner[,Interests]]", s)
+	}
+	p := send.Participant{
+		Name:  strings.TrimSpace(fields[0]),
+		Email: strings.TrimSpace(fields[1]),
+	}
+	if len(fields) > 2 {
+		p.Partner = strings.TrimSpace(fields[2])
+	}
+	if len(fields) > 3 {
+		p.Interests = send.SplitList(fields[3], ",")
+	}
+	return p, nil
+}
+
+func optional(validate func(string) error) func(string) error {
+	return func(s string) error {
+		if s == "" {
+			return nil
+		}
+		return validate(s)
+	}
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,263 @@
+package conf
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+func TestWizard_AskSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   Settings
+		answers []string
+		want    Settings
+		wantErr error
+	}{
+		{
+			name:    "Defaults accepted",
+			answers: []string{"", "mg-key", "bedrock.com", "", "", "", "", "", "", "", ""},
+			want: Settings{
+				Provider:      "mailgun",
+				APIKey:        "mg-key",
+				Domain:        "bedrock.com",
+				Subject:       "Secret Santa Assignment",
+				SenderName:    "Santa Claus",
+				SenderAddress: "santa@bedrock.com",
+				Event:         Event{Name: "Secret Santa"},
+			},
+		},
+		{
+			name:    "Flags given as defaults",
+			start:   Settings{Provider: "sendgrid", APIKey: "sg-key", Domain: "bedrock.com"},
+			answers: []string{"", "", "", "", "", "fred@bedrock.com", "Bedrock Party", "2026-12-24", "", "The quarry", "$20"},
+			want: Settings{
+				Provider:      "sendgrid",
+				APIKey:        "sg-key",
+				Domain:        "bedrock.com",
+				Subject:       "Secret Santa Assignment",
+				SenderName:    "Santa Claus",
+				SenderAddress: "fred@bedrock.com",
+				Event:         Event{Name: "Bedrock Party", Date: "2026-12-24", Location: "The quarry", Budget: "$20"},
+			},
+		},
+		{
+			name:    "Invalid answers asked again",
+			answers: []string{"pigeon", "file", "fax", "mbox", "santa.mbox", "", "bedrock.com", "", "", "not an address", "", "", "24/12/2026", "2026-12-24", "", "", ""},
+			want: Settings{
+				Provider:      "file",
+				Format:        "mbox",
+				Path:          "santa.mbox",
+				Domain:        "bedrock.com",
+				Subject:       "Secret Santa Assignment",
+				SenderName:    "Santa Claus",
+				SenderAddress: "santa@bedrock.com",
+				Event:         Event{Name: "Secret Santa", Date: "2026-12-24"},
+			},
+		},
+		{
+			name:    "ses asks for a secret and region",
+			answers: []string{"ses", "AKID", "", "shh", "eu-west-1", "bedrock.com", "", "", "", "", "", "", "", ""},
+			want: Settings{
+				Provider:      "ses",
+				APIKey:        "AKID",
+				Secret:        "shh",
+				Region:        "eu-west-1",
+				Domain:        "bedrock.com",
+				Subject:       "Secret Santa Assignment",
+				SenderName:    "Santa Claus",
+				SenderAddress: "santa@bedrock.com",
+				Event:         Event{Name: "Secret Santa"},
+			},
+		},
+		{
+			name:    "Input ends early",
+			answers: []string{"mailgun"},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := strings.NewReader(strings.Join(tt.answers, "\n") + "\n")
+			got, err := NewWizard(in, io.Discard).AskSettings(tt.start)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("AskSettings() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AskSettings() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AskSettings() = %+v, want %+v", got, tt.want)
+			}
+			if err := ValidateSettings(got); err != nil {
+				t.Errorf("ValidateSettings() rejected the wizard's settings: %v", err)
+			}
+		})
+	}
+}
+
+func TestWizard_AskParticipants(t *testing.T) {
+	existing := []send.Participant{{Name: "Fred", Email: "fred@bedrock.com"}}
+	answers := []string{
+		"Fred",
+		"Wilma", "wilma", "FRED@bedrock.com", "wilma@bedrock.com", "Fred", "Cooking, Gardening,", "Apron|||x", "Apron||$15|1",
+		"Pebbles", "pebbles@bedrock.com", "", "", "",
+		"",
+	}
+	in := strings.NewReader(strings.Join(answers, "\n") + "\n")
+	var out strings.Builder
+	got, err := NewWizard(in, &out).AskParticipants(existing)
+	if err != nil {
+		t.Fatalf("AskParticipants() error = %v", err)
+	}
+	want := []send.Participant{
+		{Name: "Fred", Email: "fred@bedrock.com"},
+		{Name: "Wilma", Email: "wilma@bedrock.com", Partner: "Fred", Interests: []string{"Cooking", "Gardening"}, Wishlist: []send.WishlistItem{{Name: "Apron", Price: "$15", Priority: 1}}},
+		{Name: "Pebbles", Email: "pebbles@bedrock.com"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AskParticipants() = %+v, want %+v", got, want)
+	}
+	for _, msg := range []string{"Fred has already been added", "FRED@bedrock.com is already used by another participant"} {
+		if !strings.Contains(out.String(), msg) {
+			t.Errorf("output doesn't say %q:\n%s", msg, out.String())
+		}
+	}
+	if err := ValidateParticipants(got); err != nil {
+		t.Errorf("ValidateParticipants() rejected the wizard's participants: %v", err)
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	valid := Settings{Provider: "mailgun", APIKey: "mg-key", Domain: "bedrock.com", SenderAddress: "santa@bedrock.com"}
+	tests := []struct {
+		name    string
+		change  func(s *Settings)
+		wantErr string
+	}{
+		{name: "Valid", change: func(s *Settings) {}},
+		{name: "Unknown provider", change: func(s *Settings) { s.Provider = "pigeon" }, wantErr: `unsupported provider "pigeon"`},
+		{name: "No api key", change: func(s *Settings) { s.APIKey = "" }, wantErr: "an api key is required"},
+		{name: "File needs no api key", change: func(s *Settings) { s.Provider, s.APIKey = "file", "" }},
+		{name: "Unknown file format", change: func(s *Settings) { s.Provider, s.Format = "file", "fax" }, wantErr: `unsupported format "fax"`},
+		{name: "ses without a secret", change: func(s *Settings) { s.Provider = "ses" }, wantErr: "ses needs a secret access key"},
+		{name: "No domain", change: func(s *Settings) { s.Domain = "" }, wantErr: "a sending domain is required"},
+		{name: "Named sender address", change: func(s *Settings) { s.SenderAddress = "Santa <santa@bedrock.com>" }, wantErr: "invalid sender address"},
+		{name: "Bad event date", change: func(s *Settings) { s.Event.Date = "Christmas" }, wantErr: "invalid event date"},
+		{name: "Bad deadline", change: func(s *Settings) { s.Event.Deadline = "2026-13-01" }, wantErr: "invalid gift deadline"},
+		{name: "Deadline on the day", change: func(s *Settings) { s.Event.Date, s.Event.Deadline = "2026-12-24", "2026-12-24" }},
+		{name: "Deadline after the event", change: func(s *Settings) { s.Event.Date, s.Event.Deadline = "2026-12-24", "2026-12-26" }, wantErr: "the gift deadline 2026-12-26 is after the event date 2026-12-24"},
+		{name: "Deadline without an event date", change: func(s *Settings) { s.Event.Deadline = "2026-12-26" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid
+			tt.change(&s)
+			err := ValidateSettings(s)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateSettings() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateSettings() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateParticipants(t *testing.T) {
+	tests := []struct {
+		name         string
+		participants []send.Participant
+		wantErr      string
+	}{
+		{
+			name:         "Valid",
+			participants: []send.Participant{{Name: "Fred", Email: "fred@bedrock.com"}, {Name: "Wilma", Email: "wilma@bedrock.com"}},
+		},
+		{
+			name:         "No name",
+			participants: []send.Participant{{Email: "fred@bedrock.com"}},
+			wantErr:      "participant with email fred@bedrock.com has no name",
+		},
+		{
+			name:         "Name listed twice",
+			participants: []send.Participant{{Name: "Fred", Email: "fred@bedrock.com"}, {Name: "Fred", Email: "fred2@bedrock.com"}},
+			wantErr:      "Fred is listed more than once",
+		},
+		{
+			name:         "Invalid email",
+			participants: []send.Participant{{Name: "Fred", Email: "fred"}},
+			wantErr:      "invalid email for Fred",
+		},
+		{
+			name:         "Email shared ignoring case",
+			participants: []send.Participant{{Name: "Fred", Email: "fred@bedrock.com"}, {Name: "Wilma", Email: "Fred@Bedrock.com"}},
+			wantErr:      "Fred@Bedrock.com is used by more than one participant",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateParticipants(tt.participants)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateParticipants() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateParticipants() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseParticipant(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    send.Participant
+		wantErr bool
+	}{
+		{
+			name: "Name and email",
+			s:    "Fred,fred@bedrock.com",
+			want: send.Participant{Name: "Fred", Email: "fred@bedrock.com"},
+		},
+		{
+			name: "Partner and interests with commas",
+			s:    " Fred , fred@bedrock.com , Wilma , Bowling, Golf ,",
+			want: send.Participant{Name: "Fred", Email: "fred@bedrock.com", Partner: "Wilma", Interests: []string{"Bowling", "Golf"}},
+		},
+		{
+			name: "Empty partner",
+			s:    "Fred,fred@bedrock.com,,Golf",
+			want: send.Participant{Name: "Fred", Email: "fred@bedrock.com", Interests: []string{"Golf"}},
+		},
+		{
+			name:    "No email",
+			s:       "Fred",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseParticipant(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseParticipant() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseParticipant() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,31 +1,54 @@
 package csvparticipantloader
 
 import (
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/dcmcand/go-secret-santa/package/provider"
 	"github.com/dcmcand/go-secret-santa/package/send"
 )
 
-type Loader struct{}
+// Loader reads participants from a csv file. Columns are matched by their
+// header, so only Name and Email are required and the order doesn't matter.
+//
+// Wishlists can be given inline as a semicolon separated Wishlist column,
+// or as one csv file per participant named <Name>.csv in WishlistDir with
+// the columns Item, URL, Price and Priority.
+type Loader struct {
+	WishlistDir string
+}
 
-func (l *Loader) LoadParticipants(path string) (send.Participants, error) {
+func (l *Loader) LoadParticipants(ctx context.Context, path string) (send.Participants, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return send.Participants{}, fmt.Errorf("error opening file: %v", err)
 	}
 	defer file.Close()
 	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
 	// Read header
-	_, err = reader.Read()
+	header, err := reader.Read()
 	if err != nil {
 		return send.Participants{}, fmt.Errorf("error reading header: %v", err)
 	}
+	columns := columnIndex(header)
+	for _, required := range []string{"name", "email"} {
+		if _, ok := columns[required]; !ok {
+			return send.Participants{}, fmt.Errorf("participants file has no %s column", required)
+		}
+	}
 	p := send.Participants{}
 	for {
+		if err := ctx.Err(); err != nil {
+			return send.Participants{}, err
+		}
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
@@ -33,14 +56,107 @@ func (l *Loader) LoadParticipants(path string) (send.Participants, error) {
 		if err != nil {
 			return send.Participants{}, fmt.Errorf("error reading file: %v\n", err)
 		}
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
 		participant := send.Participant{
-			Name:      record[0],
-			Email:     record[1],
-			Partner:   record[2],
-			Interests: strings.Split(record[3], ","),
+			Name:       field("name"),
+			Email:      field("email"),
+			Handle:     field("handle"),
+			Phone:      field("phone"),
+			Channels:   splitChannels(field("channels")),
+			Partner:    field("partner"),
+			Exclusions: send.SplitList(field("exclusions"), ";"),
+			Interests:  send.SplitList(field("interests"), ","),
+			Department: field("department"),
+			Manager:    field("manager"),
+			Country:    field("country"),
+			Address:    field("address"),
+		}
+		for _, channel := range participant.Channels {
+			if !slices.Contains(provider.Channels(), channel) {
+				return send.Participants{}, fmt.Errorf("%s has an unknown channel %q, choose from %s", participant.Name, channel, strings.Join(provider.Channels(), ", "))
+			}
+		}
+		participant.Wishlist, err = send.ParseWishlist(field("wishlist"))
+		if err != nil {
+			return send.Participants{}, fmt.Errorf("error reading wishlist for %s: %v", participant.Name, err)
+		}
+		if l.WishlistDir != "" {
+			items, err := loadWishlistFile(filepath.Join(l.WishlistDir, participant.Name+".csv"))
+			if err != nil {
+				return send.Participants{}, fmt.Errorf("error reading wishlist for %s: %v", participant.Name, err)
+			}
+			participant.Wishlist = append(participant.Wishlist, items...)
+			send.SortWishlist(participant.Wishlist)
 		}
-		p[record[0]] = participant
+		p[participant.Name] = participant
 	}
 	return p, nil
 
 }
+
+func columnIndex(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	return columns
+}
+
+// splitChannels reads a preference ordered list of channels separated by
+// semicolons or commas, e.g. "sms; email".
+func splitChannels(s string) []string {
+	return send.SplitList(strings.ToLower(s), ";,")
+}
+
+// loadWishlistFile reads a per participant wishlist. A missing file is an
+// empty wishlist.
+func loadWishlistFile(path string) ([]send.WishlistItem, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %v", err)
+	}
+	columns := columnIndex(header)
+	var items []send.WishlistItem
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		fields := make([]string, 0, 4)
+		for _, name := range []string{"item", "url", "price", "priority"} {
+			value := ""
+			if i, ok := columns[name]; ok && i < len(record) {
+				value = strings.TrimSpace(record[i])
+			}
+			fields = append(fields, value)
+		}
+		item, err := send.NewWishlistItem(fields...)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,63 @@
+package csvparticipantloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoader_LoadParticipants(t *testing.T) {
+	tests := []struct {
+		name         string
+		csv          string
+		wantChannels map[string][]string
+		wantErr      string
+	}{
+		{
+			name: "Channels in any case and separator",
+			csv:  "Name,Email,Channels\nFred,fred@bedrock.com,SMS; email\nWilma,wilma@bedrock.com,\n",
+			wantChannels: map[string][]string{
+				"Fred":  {"sms", "email"},
+				"Wilma": nil,
+			},
+		},
+		{
+			name:    "Unknown channel",
+			csv:     "Name,Email,Channels\nFred,fred@bedrock.com,sms\nWilma,wilma@bedrock.com,pigeon\n",
+			wantErr: `Wilma has an unknown channel "pigeon"`,
+		},
+		{
+			name:    "No email column",
+			csv:     "Name\nFred\n",
+			wantErr: "participants file has no email column",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "participants.csv")
+			if err := os.WriteFile(path, []byte(tt.csv), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := (&Loader{}).LoadParticipants(context.Background(), path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadParticipants() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadParticipants() error = %v", err)
+			}
+			channels := map[string][]string{}
+			for name, p := range got {
+				channels[name] = p.Channels
+			}
+			if !reflect.DeepEqual(channels, tt.wantChannels) {
+				t.Errorf("LoadParticipants() channels = %v, want %v", channels, tt.wantChannels)
+			}
+		})
+	}
+}
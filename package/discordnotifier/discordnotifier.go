@@ -0,0 +1,100 @@
+package discordnotifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+const (
+	DefaultBaseURL = "https://discord.com"
+	// maxLength is the most characters Discord accepts in one message.
+	maxLength = 2000
+)
+
+// DiscordNotifier sends each gifter a direct message from a Discord bot.
+// The participant's handle is their Discord user id, and the bot must share
+// a server with them.
+type DiscordNotifier struct {
+	token   string
+	baseURL string
+	client  *http.Client
+}
+
+// NewDiscordNotifier returns a notifier for the api at baseURL, or Discord
+// itself when baseURL is empty.
+func NewDiscordNotifier(token, baseURL string) *DiscordNotifier {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &DiscordNotifier{
+		token:   token,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (d *DiscordNotifier) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	if gifter.Handle == "" {
+		return "", fmt.Errorf("%s has no discord user id in the handle column", gifter.Name)
+	}
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering message: %v", err)
+	}
+	content := fmt.Sprintf("**%s**\n\n%s", emailTemplate.Subject, body)
+	if len([]rune(content)) > maxLength {
+		return "", fmt.Errorf("message to %s is longer than discord's %d character limit", gifter.Name, maxLength)
+	}
+
+	var channel struct {
+		ID string `json:"id"`
+	}
+	if err := d.call(ctx, "/api/v10/users/@me/channels", map[string]string{"recipient_id": gifter.Handle}, &channel); err != nil {
+		return "", fmt.Errorf("error opening a direct message with %s: %v", gifter.Name, err)
+	}
+	var message struct {
+		ID string `json:"id"`
+	}
+	msg := map[string]any{"content": content}
+	if key := send.IdempotencyKey(ctx); key != "" {
+		// Discord drops a message that repeats a recent nonce.
+		msg["nonce"], msg["enforce_nonce"] = key, true
+	}
+	if err := d.call(ctx, "/api/v10/channels/"+channel.ID+"/messages", msg, &message); err != nil {
+		return "", fmt.Errorf("error messaging %s on discord: %v", gifter.Name, err)
+	}
+	slog.Debug("message sent", "provider", "discord", "id", message.ID, "channel", channel.ID)
+	return message.ID, nil
+}
+
+func (d *DiscordNotifier) call(ctx context.Context, path string, in, out any) error {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+d.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("discord returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -1,7 +1,10 @@
 package fakemailer
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/dcmcand/go-secret-santa/package/send"
 )
@@ -9,13 +12,34 @@ import (
 // A fake mailer for testing purposes. It does not actually send emails.
 // it just logs the email that would have been sent.
 
-type Mailer struct{}
+// Channel names the channel being faked, it is left empty for email.
+// Messages are printed to Out, or stdout when it is nil.
+type Mailer struct {
+	Channel string
+	Out     io.Writer
+}
 
-func (m *Mailer) SendEmail(gifter, giftee send.Participant, emailTemplate *send.Email) error {
-	mail, err := emailTemplate.Render(gifter, giftee)
+func (m *Mailer) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	mail, err := emailTemplate.Render(gifter, giftees...)
 	if err != nil {
-		return fmt.Errorf("error rendering email: %v", err)
+		return "", fmt.Errorf("error rendering email: %v", err)
+	}
+	out := m.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	switch m.Channel {
+	case "", "email":
+		fmt.Fprintf(out, "\nEmail to %s <%s>:\n%s\n", gifter.Name, gifter.Email, mail)
+		for _, a := range emailTemplate.Attachments {
+			fmt.Fprintf(out, "Attached: %s (%s, %d bytes)\n", a.Filename, a.ContentType, len(a.Data))
+		}
+	case "print":
+		fmt.Fprintf(out, "\nEnvelope for %s:\n%s\n", gifter.Name, mail)
+	case "sms":
+		fmt.Fprintf(out, "\nText to %s <%s>:\n%s\n", gifter.Name, gifter.Phone, mail)
+	default:
+		fmt.Fprintf(out, "\n%s message to %s <%s>:\n%s\n", m.Channel, gifter.Name, gifter.Handle, mail)
 	}
-	fmt.Printf("\nEmail to %s <%s>:\n%s\n", gifter.Name, gifter.Email, mail)
-	return nil
+	return "", nil
 }
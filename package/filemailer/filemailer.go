@@ -0,0 +1,220 @@
+package filemailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/mimemessage"
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// Formats the messages can be written in.
+const (
+	// EML writes one <Name>.eml file per message into a directory. Files
+	// are never overwritten, see writeEML.
+	EML = "eml"
+	// Mbox appends every message to a single mbox file.
+	Mbox = "mbox"
+	// Maildir delivers every message into the new folder of a Maildir.
+	Maildir = "maildir"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{EML, Mbox, Maildir}
+
+// FileEmailer writes RFC 5322 messages to disk instead of sending them, so
+// organizers can hand them out or import them into their own mail client.
+type FileEmailer struct {
+	format string
+	path   string
+	now    func() time.Time
+
+	mu sync.Mutex
+}
+
+// sequence keeps message ids and Maildir names unique across every emailer
+// in the process.
+var sequence atomic.Int64
+
+// NewFileEmailer returns an emailer writing to path in format, one of
+// Formats.
+func NewFileEmailer(format, path string) (*FileEmailer, error) {
+	format = strings.ToLower(format)
+	if format == "" {
+		format = EML
+	}
+	switch format {
+	case EML, Mbox, Maildir:
+	default:
+		return nil, fmt.Errorf("unknown file format %q, choose one of %s", format, strings.Join(Formats, ", "))
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no path to write %s messages to", format)
+	}
+	return &FileEmailer{format: format, path: path, now: time.Now}, nil
+}
+
+func (m *FileEmailer) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering email: %v", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	seq := sequence.Add(1)
+	messageID := send.MessageID(ctx, emailTemplate.SenderEmail)
+	if messageID == "" {
+		messageID = m.messageID(emailTemplate.SenderEmail, seq)
+	}
+	msg, err := m.message(gifter, emailTemplate, body, messageID)
+	if err != nil {
+		return "", err
+	}
+	switch m.format {
+	case Mbox:
+		err = m.writeMbox(emailTemplate.SenderEmail, msg)
+	case Maildir:
+		err = m.writeMaildir(msg, seq)
+	default:
+		err = m.writeEML(gifter.Name, send.IdempotencyKey(ctx), msg)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error writing email for %s: %v", gifter.Name, err)
+	}
+	return messageID, nil
+}
+
+func (m *FileEmailer) messageID(sender string, seq int64) string {
+	domain := "localhost"
+	if i := strings.LastIndex(sender, "@"); i >= 0 {
+		domain = sender[i+1:]
+	}
+	return fmt.Sprintf("<%d.%d.%d@%s>", m.now().UnixNano(), os.Getpid(), seq, domain)
+}
+
+// message builds the email with CRLF line endings.
+func (m *FileEmailer) message(gifter send.Participant, emailTemplate *send.Email, body, messageID string) ([]byte, error) {
+	return mimemessage.Build(mimemessage.Header{
+		From:      mail.Address{Name: emailTemplate.SenderName, Address: emailTemplate.SenderEmail},
+		To:        mail.Address{Name: gifter.Name, Address: gifter.Email},
+		Subject:   emailTemplate.Subject,
+		Date:      m.now(),
+		MessageID: messageID,
+	}, body, emailTemplate.Attachments)
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^\p{L}\p{N} ._-]+`)
+
+// writeEML names the file after the gifter and the delivery key, so
+// gifters whose names clean up the same way get their own files. A file
+// for the same delivery key is the same message and is left as it is.
+// Without a key the first free name of <Name>.eml, <Name>-2.eml and so on
+// is used.
+func (m *FileEmailer) writeEML(name, key string, msg []byte) error {
+	if err := os.MkdirAll(m.path, 0o700); err != nil {
+		return err
+	}
+	name = strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), " .")
+	if name == "" {
+		name = "message"
+	}
+	if key != "" {
+		err := writeNew(filepath.Join(m.path, name+"-"+key+".eml"), msg)
+		if errors.Is(err, fs.ErrExist) {
+			slog.Debug("message already written", "provider", "file", "key", key)
+			return nil
+		}
+		return err
+	}
+	for n := 1; ; n++ {
+		file := name + ".eml"
+		if n > 1 {
+			file = fmt.Sprintf("%s-%d.eml", name, n)
+		}
+		if err := writeNew(filepath.Join(m.path, file), msg); !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+}
+
+// writeNew writes msg to path, failing with fs.ErrExist if it is there
+// already.
+func writeNew(path string, msg []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(msg); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// mboxFromLine matches lines that need escaping in the mboxrd format.
+var mboxFromLine = regexp.MustCompile(`(?m)^(>*From )`)
+
+func (m *FileEmailer) writeMbox(sender string, msg []byte) error {
+	if dir := filepath.Dir(m.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	if sender == "" {
+		sender = "MAILER-DAEMON"
+	}
+	msg = bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n"))
+	msg = mboxFromLine.ReplaceAll(msg, []byte(">$1"))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From %s %s\n", sender, m.now().UTC().Format(time.ANSIC))
+	b.Write(msg)
+	b.WriteString("\n")
+
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeMaildir writes into tmp and then moves the message into new, so mail
+// clients never see half written messages.
+func (m *FileEmailer) writeMaildir(msg []byte, seq int64) error {
+	for _, dir := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(m.path, dir), 0o700); err != nil {
+			return err
+		}
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	host = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+	name := fmt.Sprintf("%d.P%dQ%d.%s", m.now().Unix(), os.Getpid(), seq, host)
+	tmp := filepath.Join(m.path, "tmp", name)
+	if err := os.WriteFile(tmp, msg, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(m.path, "new", name))
+}
@@ -0,0 +1,215 @@
+package filemailer
+
+import (
+	"context"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+func TestFileEmailer_Notify(t *testing.T) {
+	email := &send.Email{
+		Subject:     "Secret Santa 🎅",
+		Body:        template.Must(template.New("body").Parse("Hi {{.Gifter.Name}},\nyou're buying for {{.GifteeNames}}.\nFrom the elves\n")),
+		SenderName:  "Santa Claus",
+		SenderEmail: "santa@bedrock.org",
+	}
+	gifters := []send.Participant{
+		{Name: "Fred", Email: "fred@bedrock.org"},
+		{Name: "Wilma", Email: "wilma@bedrock.org"},
+	}
+	giftees := []send.Participant{{Name: "Barney"}}
+
+	tests := []struct {
+		name   string
+		format string
+		path   string
+		// files lists the messages written, relative to the temp dir.
+		files func(t *testing.T, dir string) []string
+	}{
+		{
+			name:   "One eml per gifter",
+			format: EML,
+			path:   "out",
+			files: func(t *testing.T, dir string) []string {
+				return []string{filepath.Join(dir, "out", "Fred.eml"), filepath.Join(dir, "out", "Wilma.eml")}
+			},
+		},
+		{
+			name:   "Maildir",
+			format: Maildir,
+			path:   "Maildir",
+			files: func(t *testing.T, dir string) []string {
+				files, err := filepath.Glob(filepath.Join(dir, "Maildir", "new", "*"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tmp, _ := os.ReadDir(filepath.Join(dir, "Maildir", "tmp")); len(tmp) != 0 {
+					t.Errorf("messages left in tmp: %v", tmp)
+				}
+				return files
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			m, err := NewFileEmailer(tt.format, filepath.Join(dir, tt.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, gifter := range gifters {
+				if _, err := m.Notify(context.Background(), gifter, giftees, email); err != nil {
+					t.Fatalf("Notify() error = %v", err)
+				}
+			}
+			files := tt.files(t, dir)
+			if len(files) != len(gifters) {
+				t.Fatalf("wrote %d messages, want %d", len(files), len(gifters))
+			}
+			for _, file := range files {
+				f, err := os.Open(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				msg, err := mail.ReadMessage(f)
+				f.Close()
+				if err != nil {
+					t.Fatalf("%s is not a valid message: %v", file, err)
+				}
+				subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+				if err != nil || subject != email.Subject {
+					t.Errorf("Subject = %q, want %q", subject, email.Subject)
+				}
+				if _, err := msg.Header.AddressList("To"); err != nil {
+					t.Errorf("bad To header: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestFileEmailer_NotifyMbox(t *testing.T) {
+	email := &send.Email{
+		Subject:     "Secret Santa",
+		Body:        template.Must(template.New("body").Parse("Hi {{.Gifter.Name}},\nFrom the elves with love\n")),
+		SenderEmail: "santa@bedrock.org",
+	}
+	path := filepath.Join(t.TempDir(), "santa.mbox")
+	m, err := NewFileEmailer(Mbox, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return time.Date(2024, 12, 1, 9, 0, 0, 0, time.UTC) }
+	for _, name := range []string{"Fred", "Wilma"} {
+		if _, err := m.Notify(context.Background(), send.Participant{Name: name, Email: strings.ToLower(name) + "@bedrock.org"}, nil, email); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	if n := strings.Count(got, "\nFrom santa@bedrock.org Sun Dec  1 09:00:00 2024\n"); n != 1 || !strings.HasPrefix(got, "From santa@bedrock.org ") {
+		t.Errorf("want two messages separated by From lines, got:\n%s", got)
+	}
+	if !strings.Contains(got, "\n>From the elves") {
+		t.Errorf("body From line not escaped:\n%s", got)
+	}
+	if strings.Contains(got, "\r\n") {
+		t.Errorf("mbox should use unix line endings")
+	}
+}
+
+func TestNewFileEmailer(t *testing.T) {
+	if _, err := NewFileEmailer("pdf", "out"); err == nil {
+		t.Error("NewFileEmailer() accepted an unknown format")
+	}
+	if _, err := NewFileEmailer(EML, ""); err == nil {
+		t.Error("NewFileEmailer() accepted an empty path")
+	}
+}
+
+func TestFileEmailer_NotifyEMLNeverOverwrites(t *testing.T) {
+	email := &send.Email{
+		Subject:     "Secret Santa",
+		Body:        template.Must(template.New("body").Parse("Hi {{.Gifter.Name}}\n")),
+		SenderEmail: "santa@bedrock.org",
+	}
+	// Both names clean up to "Fred_"
+	gifters := []send.Participant{
+		{Name: "Fred?", Email: "fred@bedrock.org"},
+		{Name: "Fred*", Email: "fred2@bedrock.org"},
+	}
+	tests := []struct {
+		name string
+		keys []string
+		want []string
+	}{
+		{
+			name: "Without a key",
+			keys: []string{"", ""},
+			want: []string{"Fred_-2.eml", "Fred_.eml"},
+		},
+		{
+			name: "With delivery keys",
+			keys: []string{"k1", "k2"},
+			want: []string{"Fred_-k1.eml", "Fred_-k2.eml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			m, err := NewFileEmailer(EML, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, gifter := range gifters {
+				ctx := send.WithIdempotencyKey(context.Background(), tt.keys[i])
+				if _, err := m.Notify(ctx, gifter, nil, email); err != nil {
+					t.Fatalf("Notify() error = %v", err)
+				}
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("wrote %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A resend with the same key leaves the first message alone
+	dir := t.TempDir()
+	m, err := NewFileEmailer(EML, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := send.WithIdempotencyKey(context.Background(), "k1")
+	path := filepath.Join(dir, "Fred-k1.eml")
+	if _, err := m.Notify(ctx, send.Participant{Name: "Fred"}, nil, email); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("first"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Notify(ctx, send.Participant{Name: "Fred"}, nil, email); err != nil {
+		t.Fatalf("Notify() error = %v for a resend", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "first" {
+		t.Errorf("resend overwrote %s", path)
+	}
+}
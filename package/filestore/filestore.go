@@ -0,0 +1,52 @@
+// Package filestore holds what the stores kept as files on disk share.
+package filestore
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to a temporary file next to path, syncs it and
+// renames it into place, so a crash never leaves half a file behind and
+// nothing ever reads one half written. The file can only be read by its
+// owner.
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// SigningKey creates dir if needed and returns the key in it that links
+// are signed with, making a random one the first time.
+func SigningKey(dir string) ([]byte, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	keyPath := filepath.Join(dir, "secret.key")
+	key, err := os.ReadFile(keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		key = make([]byte, 32)
+		rand.Read(key)
+		err = WriteFile(keyPath, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
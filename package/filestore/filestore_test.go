@@ -0,0 +1,54 @@
+package filestore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "draw.json")
+	for _, data := range []string{"first", "second"} {
+		if err := WriteFile(path, []byte(data)); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != data {
+			t.Errorf("file holds %q, %v, want %q", got, err, data)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("file mode = %v, want only its owner to read it", perm)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("WriteFile() left %d files behind, want 1", len(entries))
+	}
+	if err := WriteFile(filepath.Join(dir, "missing", "draw.json"), nil); err == nil {
+		t.Errorf("WriteFile() into a missing directory should return an error")
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reveals")
+	key, err := SigningKey(dir)
+	if err != nil {
+		t.Fatalf("SigningKey() error = %v", err)
+	}
+	if len(key) != 32 {
+		t.Errorf("SigningKey() made a %d byte key, want 32", len(key))
+	}
+	again, err := SigningKey(dir)
+	if err != nil {
+		t.Fatalf("SigningKey() error = %v", err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Errorf("SigningKey() made a new key when one was already saved")
+	}
+}
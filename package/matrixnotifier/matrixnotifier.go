@@ -0,0 +1,169 @@
+package matrixnotifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// MatrixNotifier sends each gifter a direct message from a Matrix account.
+// The participant's handle is their full user id, e.g. @fred:bedrock.org.
+// The direct room with each gifter is looked up in the account's m.direct
+// data, so a room is only created the first time someone is messaged.
+type MatrixNotifier struct {
+	homeserver  string
+	accessToken string
+	client      *http.Client
+
+	// mu guards finding and recording direct rooms, so two messages to
+	// the same gifter don't both create one.
+	mu     sync.Mutex
+	userID string
+}
+
+// matrixError is an error response from the homeserver.
+type matrixError struct {
+	Status  string
+	ErrCode string `json:"errcode"`
+	Message string `json:"error"`
+}
+
+func (e *matrixError) Error() string {
+	return fmt.Sprintf("matrix returned %s: %s %s", e.Status, e.ErrCode, e.Message)
+}
+
+// NewMatrixNotifier uses the client-server api of the homeserver at
+// homeserver, e.g. https://matrix.org.
+func NewMatrixNotifier(homeserver, accessToken string) *MatrixNotifier {
+	return &MatrixNotifier{
+		homeserver:  strings.TrimRight(homeserver, "/"),
+		accessToken: accessToken,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (m *MatrixNotifier) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	if !strings.HasPrefix(gifter.Handle, "@") || !strings.Contains(gifter.Handle, ":") {
+		return "", fmt.Errorf("%s needs a matrix user id like @name:server in the handle column", gifter.Name)
+	}
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering message: %v", err)
+	}
+
+	room, err := m.directRoom(ctx, gifter.Handle, emailTemplate.Subject)
+	if err != nil {
+		return "", fmt.Errorf("error finding a direct room with %s: %v", gifter.Name, err)
+	}
+
+	// The transaction id makes the homeserver ignore a repeat of the same
+	// message, so it's the idempotency key when there is one.
+	txn := send.IdempotencyKey(ctx)
+	if txn == "" {
+		id := make([]byte, 8)
+		rand.Read(id)
+		txn = hex.EncodeToString(id)
+	}
+	var event struct {
+		EventID string `json:"event_id"`
+	}
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", url.PathEscape(room), url.PathEscape(txn))
+	err = m.call(ctx, http.MethodPut, path, map[string]string{
+		"msgtype": "m.text",
+		"body":    emailTemplate.Subject + "\n\n" + body,
+	}, &event)
+	if err != nil {
+		return "", fmt.Errorf("error messaging %s on matrix: %v", gifter.Name, err)
+	}
+	slog.Debug("message sent", "provider", "matrix", "id", event.EventID, "room", room)
+	return event.EventID, nil
+}
+
+// directRoom returns the latest direct room with user listed in the
+// account's m.direct data, creating and recording one if there is none.
+func (m *MatrixNotifier) directRoom(ctx context.Context, user, name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.userID == "" {
+		var whoami struct {
+			UserID string `json:"user_id"`
+		}
+		if err := m.call(ctx, http.MethodGet, "/_matrix/client/v3/account/whoami", nil, &whoami); err != nil {
+			return "", err
+		}
+		m.userID = whoami.UserID
+	}
+	direct := make(map[string][]string)
+	path := fmt.Sprintf("/_matrix/client/v3/user/%s/account_data/m.direct", url.PathEscape(m.userID))
+	var failure *matrixError
+	if err := m.call(ctx, http.MethodGet, path, nil, &direct); errors.As(err, &failure) && failure.ErrCode == "M_NOT_FOUND" {
+		direct = make(map[string][]string)
+	} else if err != nil {
+		return "", err
+	}
+	if rooms := direct[user]; len(rooms) > 0 {
+		return rooms[len(rooms)-1], nil
+	}
+
+	var room struct {
+		RoomID string `json:"room_id"`
+	}
+	err := m.call(ctx, http.MethodPost, "/_matrix/client/v3/createRoom", map[string]any{
+		"is_direct": true,
+		"invite":    []string{user},
+		"preset":    "trusted_private_chat",
+		"name":      name,
+	}, &room)
+	if err != nil {
+		return "", err
+	}
+	direct[user] = append(direct[user], room.RoomID)
+	if err := m.call(ctx, http.MethodPut, path, direct, &struct{}{}); err != nil {
+		slog.Warn("direct room not recorded, a new one will be created next time", "provider", "matrix", "room", room.RoomID, "err", err)
+	}
+	return room.RoomID, nil
+}
+
+func (m *MatrixNotifier) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, m.homeserver+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		failure := &matrixError{Status: resp.Status}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		json.Unmarshal(raw, failure)
+		return failure
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,129 @@
+package mattermostnotifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// MattermostNotifier sends each gifter a direct message from a Mattermost
+// bot account. The participant's handle is their Mattermost username.
+type MattermostNotifier struct {
+	baseURL string
+	token   string
+	client  *http.Client
+
+	mu    sync.Mutex
+	botID string
+}
+
+// NewMattermostNotifier uses the api of the server at baseURL, e.g.
+// https://chat.example.com, with a bot access token.
+func NewMattermostNotifier(baseURL, token string) *MattermostNotifier {
+	return &MattermostNotifier{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify posts in the direct channel between the bot and the gifter and
+// returns the post id.
+func (m *MattermostNotifier) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	if gifter.Handle == "" {
+		return "", fmt.Errorf("%s has no mattermost username in the handle column", gifter.Name)
+	}
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering message: %v", err)
+	}
+	botID, err := m.bot(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error finding the mattermost bot account: %v", err)
+	}
+	var user struct {
+		ID string `json:"id"`
+	}
+	if err := m.call(ctx, http.MethodGet, "/api/v4/users/username/"+url.PathEscape(strings.TrimPrefix(gifter.Handle, "@")), nil, &user); err != nil {
+		return "", fmt.Errorf("error finding %s on mattermost: %v", gifter.Name, err)
+	}
+	// The server returns the existing direct channel if there is one.
+	var channel struct {
+		ID string `json:"id"`
+	}
+	if err := m.call(ctx, http.MethodPost, "/api/v4/channels/direct", []string{botID, user.ID}, &channel); err != nil {
+		return "", fmt.Errorf("error opening a direct message with %s: %v", gifter.Name, err)
+	}
+	var post struct {
+		ID string `json:"id"`
+	}
+	err = m.call(ctx, http.MethodPost, "/api/v4/posts", map[string]string{
+		"channel_id": channel.ID,
+		"message":    fmt.Sprintf("**%s**\n\n%s", emailTemplate.Subject, body),
+	}, &post)
+	if err != nil {
+		return "", fmt.Errorf("error messaging %s on mattermost: %v", gifter.Name, err)
+	}
+	slog.Debug("message sent", "provider", "mattermost", "id", post.ID, "channel", channel.ID)
+	return post.ID, nil
+}
+
+// bot returns the user id of the bot account, looked up once.
+func (m *MattermostNotifier) bot(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.botID != "" {
+		return m.botID, nil
+	}
+	var me struct {
+		ID string `json:"id"`
+	}
+	if err := m.call(ctx, http.MethodGet, "/api/v4/users/me", nil, &me); err != nil {
+		return "", err
+	}
+	m.botID = me.ID
+	return m.botID, nil
+}
+
+func (m *MattermostNotifier) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, m.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		json.Unmarshal(raw, &failure)
+		return fmt.Errorf("mattermost returned %s: %s", resp.Status, failure.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
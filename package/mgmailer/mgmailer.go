@@ -1,11 +1,15 @@
 package mgmailer
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/mail"
 	"time"
 
+	"github.com/dcmcand/go-secret-santa/package/mimemessage"
 	"github.com/dcmcand/go-secret-santa/package/send"
 	"github.com/mailgun/mailgun-go/v4"
 )
@@ -15,30 +19,57 @@ type MailgunEmailer struct {
 	mg            mailgun.Mailgun
 }
 
-func (m *MailgunEmailer) SendEmail(gifter, giftee send.Participant, emailTemplate *send.Email) error {
+func (m *MailgunEmailer) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
 	// The message object allows you to add attachments and Bcc recipients
-	body, err := emailTemplate.Render(gifter, giftee)
+	body, err := emailTemplate.Render(gifter, giftees...)
 	if err != nil {
-		return fmt.Errorf("error rendering email: %v", err)
+		return "", fmt.Errorf("error rendering email: %v", err)
+	}
+	var message *mailgun.Message
+	if len(emailTemplate.Attachments) == 0 {
+		message = mailgun.NewMessage(emailTemplate.SenderEmail, emailTemplate.Subject, body, gifter.Email)
+		if id := send.MessageID(ctx, emailTemplate.SenderEmail); id != "" {
+			message.AddHeader("Message-ID", id)
+		}
+	} else {
+		// Mailgun sends attachments added to a message as
+		// application/octet-stream, so send the whole MIME message to keep
+		// each attachment's content type
+		raw, err := mimemessage.Build(mimemessage.Header{
+			From:      mail.Address{Name: emailTemplate.SenderName, Address: emailTemplate.SenderEmail},
+			To:        mail.Address{Name: gifter.Name, Address: gifter.Email},
+			Subject:   emailTemplate.Subject,
+			Date:      time.Now(),
+			MessageID: send.MessageID(ctx, emailTemplate.SenderEmail),
+		}, body, emailTemplate.Attachments)
+		if err != nil {
+			return "", err
+		}
+		message = mailgun.NewMIMEMessage(io.NopCloser(bytes.NewReader(raw)), gifter.Email)
 	}
-	message := mailgun.NewMessage(emailTemplate.SenderEmail, emailTemplate.Subject, body, gifter.Email)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 
 	// Send the message with a 10 second timeout
 	resp, id, err := m.mg.Send(ctx, message)
 
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("error sending email with mailgun: %v", err)
 	}
 
-	fmt.Printf("ID: %s Resp: %s\n", id, resp)
-	return nil
+	slog.Debug("email accepted", "provider", "mailgun", "id", id, "response", resp)
+	return id, nil
 }
 
-func NewMailgunEmailer(domain, apiKey string) *MailgunEmailer {
+// NewMailgunEmailer sends through the mailgun api. An empty baseURL uses
+// mailgun's US endpoint.
+func NewMailgunEmailer(domain, apiKey, baseURL string) *MailgunEmailer {
+	mg := mailgun.NewMailgun(domain, apiKey)
+	if baseURL != "" {
+		mg.SetAPIBase(baseURL)
+	}
 	return &MailgunEmailer{
-		mg: mailgun.NewMailgun(domain, apiKey),
+		mg: mg,
 	}
 }
@@ -0,0 +1,110 @@
+package mimemessage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// Header holds the headers of a message.
+type Header struct {
+	From      mail.Address
+	To        mail.Address
+	Subject   string
+	Date      time.Time
+	MessageID string
+}
+
+// Build encodes an RFC 5322 message with CRLF line endings. The body is
+// plain text, and with attachments the message becomes multipart/mixed.
+func Build(h Header, body string, attachments []send.Attachment) ([]byte, error) {
+	var b bytes.Buffer
+	header := func(name, value string) { fmt.Fprintf(&b, "%s: %s\r\n", name, value) }
+	header("From", h.From.String())
+	header("To", h.To.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", h.Subject))
+	header("Date", h.Date.Format(time.RFC1123Z))
+	if h.MessageID != "" {
+		header("Message-ID", h.MessageID)
+	}
+	header("MIME-Version", "1.0")
+
+	if len(attachments) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		if err := writeText(&b, body); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	w := multipart.NewWriter(&b)
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": w.Boundary()}))
+	b.WriteString("\r\n")
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding email: %v", err)
+	}
+	if err := writeText(part, body); err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		if mediaType, params, err := mime.ParseMediaType(contentType); err == nil {
+			params["name"] = a.Filename
+			contentType = mime.FormatMediaType(mediaType, params)
+		}
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error encoding attachment %s: %v", a.Filename, err)
+		}
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("error encoding email: %v", err)
+	}
+	b.WriteString("\r\n")
+	return b.Bytes(), nil
+}
+
+// writeText writes body as quoted-printable, ending with a line break.
+func writeText(w io.Writer, body string) error {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return fmt.Errorf("error encoding email: %v", err)
+	}
+	if err := qp.Close(); err != nil {
+		return fmt.Errorf("error encoding email: %v", err)
+	}
+	return nil
+}
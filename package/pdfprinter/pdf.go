@@ -0,0 +1,242 @@
+package pdfprinter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// The standard PDF fonts don't need embedding, which keeps the writer
+// small. They only cover Windows-1252, so other characters print as "?".
+const (
+	regular = "F1"
+	bold    = "F2"
+)
+
+var fontNames = map[string]string{regular: "Helvetica", bold: "Helvetica-Bold"}
+
+// document is a minimal PDF writer: pages of text and lines in the
+// standard fonts.
+type document struct {
+	width, height float64
+	pages         []*page
+}
+
+type page struct {
+	bytes.Buffer
+}
+
+func newDocument(width, height float64) *document {
+	return &document{width: width, height: height}
+}
+
+func (d *document) addPage() *page {
+	p := &page{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// text draws s with its baseline starting at x, y.
+func (p *page) text(font string, size, x, y float64, s string) {
+	fmt.Fprintf(p, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(size), num(x), num(y), escape(s))
+}
+
+// centred draws s centred on x.
+func (p *page) centred(font string, size, x, y float64, s string) {
+	p.text(font, size, x-textWidth(font, size, s)/2, y, s)
+}
+
+// dashed draws a dashed fold line.
+func (p *page) dashed(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(p, "q 0.6 G 0.5 w [4 4] 0 d %s %s m %s %s l S Q\n", num(x1), num(y1), num(x2), num(y2))
+}
+
+// rotated runs draw with the page turned upside down, so the half of the
+// sheet that is folded behind reads the right way up.
+func (p *page) rotated(width, height float64, draw func()) {
+	fmt.Fprintf(p, "q -1 0 0 -1 %s %s cm\n", num(width), num(height))
+	draw()
+	p.WriteString("Q\n")
+}
+
+func (d *document) WriteTo(w io.Writer) (int64, error) {
+	out := &countingWriter{w: bufio.NewWriter(w)}
+	var offsets []int64
+	object := func(body string) {
+		offsets = append(offsets, out.n)
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1 to 4 are the catalog, page tree and fonts, then each page
+	// is followed by its content stream.
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %s %s] >>",
+		strings.Join(kids, " "), len(d.pages), num(d.width), num(d.height)))
+	for _, font := range []string{regular, bold} {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", fontNames[font]))
+	}
+	for i, p := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			regular, bold, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", p.Len(), p.String()))
+	}
+
+	xref := out.n
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	if out.err != nil {
+		return out.n, out.err
+	}
+	return out.n, out.w.Flush()
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+func (c *countingWriter) WriteString(s string) (int, error) {
+	return c.Write([]byte(s))
+}
+
+func num(f float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", f), "0"), ".")
+}
+
+// escape encodes s as Windows-1252 and escapes it for a PDF string.
+func escape(s string) string {
+	var b strings.Builder
+	for _, c := range winAnsi(s) {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			if c < 32 || c > 126 {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// winAnsiSpecials are the characters Windows-1252 places in 0x80 to 0x9f.
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+func winAnsi(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			b = append(b, ' ')
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			b = append(b, byte(r))
+		case winAnsiSpecials[r] != 0:
+			b = append(b, winAnsiSpecials[r])
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// Widths of the printable ASCII characters from the Helvetica metrics, in
+// thousandths of the font size. Everything else is treated as 556.
+var widths = map[string][95]int{
+	regular: {
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	},
+	bold: {
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	},
+}
+
+func textWidth(font string, size float64, s string) float64 {
+	total := 0
+	for _, c := range winAnsi(s) {
+		if c >= 32 && c <= 126 {
+			total += widths[font][c-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// wrap breaks text into lines no wider than width, splitting words that
+// are too long on their own, such as urls.
+func wrap(font string, size, width float64, text string) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		indent := paragraph[:len(paragraph)-len(strings.TrimLeft(paragraph, " "))]
+		line := indent
+		for _, word := range strings.Fields(paragraph) {
+			candidate := line + word
+			if line != indent {
+				candidate = line + " " + word
+			}
+			if textWidth(font, size, candidate) <= width {
+				line = candidate
+				continue
+			}
+			if line != indent {
+				lines = append(lines, line)
+				line = indent
+			}
+			for word != "" && textWidth(font, size, line+word) > width {
+				split := 1
+				for split < len(word) && textWidth(font, size, line+word[:split+1]) <= width {
+					split++
+				}
+				for split < len(word) && !utf8.RuneStart(word[split]) {
+					split++
+				}
+				lines = append(lines, line+word[:split])
+				word = word[split:]
+				line = indent
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,188 @@
+package pdfprinter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// Layouts the envelopes can be printed in.
+const (
+	// Single prints every envelope of a run as a page of one PDF. A run
+	// never replaces the PDF of an earlier one, see claim.
+	Single = "single"
+	// Separate prints one <Name>.pdf per gifter into a directory.
+	Separate = "separate"
+)
+
+// Layouts lists the supported layouts.
+var Layouts = []string{Single, Separate}
+
+// US Letter, in points.
+const (
+	pageWidth  = 612
+	pageHeight = 792
+	margin     = 36
+)
+
+// PDFPrinter prints each assignment as a sealed envelope: a letter sized
+// sheet folded into a card. The gifter's name is on the front and the
+// rendered message is on the inside, hidden until the card is opened.
+//
+// Notify rewrites the whole PDF in the Single layout, so the file on disk
+// always holds every envelope printed so far.
+type PDFPrinter struct {
+	layout string
+	path   string
+
+	mu  sync.Mutex
+	doc *document
+	// file is the PDF this printer claimed for the Single layout.
+	file string
+}
+
+// NewPDFPrinter returns a printer writing to path in layout, one of
+// Layouts. An empty path prints to envelopes.pdf, or the envelopes
+// directory for the Separate layout.
+func NewPDFPrinter(layout, path string) (*PDFPrinter, error) {
+	layout = strings.ToLower(layout)
+	switch layout {
+	case "":
+		layout = Single
+	case Single, Separate:
+	default:
+		return nil, fmt.Errorf("unknown pdf layout %q, choose one of %s", layout, strings.Join(Layouts, ", "))
+	}
+	if path == "" {
+		path = "envelopes.pdf"
+		if layout == Separate {
+			path = "envelopes"
+		}
+	}
+	return &PDFPrinter{layout: layout, path: path, doc: newDocument(pageWidth, pageHeight)}, nil
+}
+
+func (m *PDFPrinter) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering envelope: %v", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	doc := m.doc
+	path := m.file
+	if m.layout == Separate {
+		doc = newDocument(pageWidth, pageHeight)
+		path = filepath.Join(m.path, fileName(gifter.Name)+".pdf")
+	}
+	if m.layout == Single && m.file == "" {
+		if err := m.claim(); err != nil {
+			return "", fmt.Errorf("error writing envelope for %s: %v", gifter.Name, err)
+		}
+		path = m.file
+	}
+	envelope(doc.addPage(), emailTemplate.Subject, gifter.Name, body)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("error writing envelope for %s: %v", gifter.Name, err)
+	}
+	var b bytes.Buffer
+	if _, err := doc.WriteTo(&b); err != nil {
+		return "", fmt.Errorf("error writing envelope for %s: %v", gifter.Name, err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o600); err != nil {
+		return "", fmt.Errorf("error writing envelope for %s: %v", gifter.Name, err)
+	}
+	return path, nil
+}
+
+// claim picks the PDF for this run: path if it is free, or else the first
+// free of <name>-2.pdf, <name>-3.pdf and so on, so envelopes printed by an
+// earlier run are kept. The file is created empty to reserve it.
+func (m *PDFPrinter) claim() error {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o700); err != nil {
+		return err
+	}
+	ext := filepath.Ext(m.path)
+	base := strings.TrimSuffix(m.path, ext)
+	for n := 1; ; n++ {
+		path := m.path
+		if n > 1 {
+			path = fmt.Sprintf("%s-%d%s", base, n, ext)
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		m.file = path
+		return f.Close()
+	}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^\p{L}\p{N} ._-]+`)
+
+func fileName(name string) string {
+	name = strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), " .")
+	if name == "" {
+		return "envelope"
+	}
+	return name
+}
+
+// envelope lays out a quarter fold card. The bottom right quarter is the
+// front with the gifter's name and the top half, printed upside down, is
+// the inside. Folding the top half behind and then folding the sheet in
+// half again hides the inside with a single sided print.
+func envelope(p *page, title, name, body string) {
+	const half, quarter = pageHeight / 2, pageWidth / 2
+	p.dashed(0, half, pageWidth, half)
+	p.dashed(quarter, 0, quarter, half)
+
+	// Front
+	size := 28.0
+	for size > 12 && textWidth(bold, size, name) > quarter-2*margin {
+		size--
+	}
+	p.centred(regular, 12, quarter+quarter/2, half/2+50, title)
+	p.centred(bold, size, quarter+quarter/2, half/2, name)
+	p.centred(regular, 9, quarter+quarter/2, half/2-30, "Open when nobody is looking")
+
+	// Back
+	p.text(regular, 8, margin, margin+12, "1. Fold the top half behind along the dashed line.")
+	p.text(regular, 8, margin, margin, "2. Fold in half so "+name+"'s name is on the front.")
+
+	// Inside
+	p.rotated(pageWidth, pageHeight, func() {
+		size, leading := 12.0, 15.0
+		lines := wrap(regular, size, pageWidth-2*margin, strings.TrimSpace(body))
+		for size > 7 && float64(len(lines))*leading > half-2*margin {
+			size--
+			leading = size * 1.25
+			lines = wrap(regular, size, pageWidth-2*margin, strings.TrimSpace(body))
+		}
+		if fit := int((half - 2*margin) / leading); len(lines) > fit {
+			lines = append(lines[:fit-1], "...")
+		}
+		y := half - margin - size
+		for _, line := range lines {
+			p.text(regular, size, margin, y, line)
+			y -= leading
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package pdfprinter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// checkPDF makes sure the cross reference table points at every object
+// and returns the page count.
+func checkPDF(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := string(data)
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("%s is not a pdf", path)
+	}
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	if m == nil {
+		t.Fatalf("%s has no startxref", path)
+	}
+	xref, _ := strconv.Atoi(m[1])
+	if !strings.HasPrefix(pdf[xref:], "xref\n") {
+		t.Fatalf("startxref %d doesn't point at the xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(pdf[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(pdf[offset:], want) {
+			t.Errorf("xref entry %d points at %q", i+1, pdf[offset:min(offset+10, len(pdf))])
+		}
+	}
+	count := regexp.MustCompile(`/Count (\d+)`).FindStringSubmatch(pdf)
+	if count == nil {
+		t.Fatalf("%s has no page count", path)
+	}
+	n, _ := strconv.Atoi(count[1])
+	return n
+}
+
+func TestPDFPrinter_Notify(t *testing.T) {
+	email := &send.Email{
+		Subject: "Secret Santa",
+		Body: template.Must(template.New("body").Parse(
+			"You are buying for {{.GifteeNames}} (wishlist: {{range .Giftee.Wishlist}}{{.}} {{end}})")),
+	}
+	gifters := []send.Participant{{Name: "Fred"}, {Name: "Wilma"}}
+	giftees := []send.Participant{{
+		Name:     "Barney",
+		Wishlist: []send.WishlistItem{{Name: "Bowling ball", URL: "https://example.com/" + strings.Repeat("ball", 50)}},
+	}}
+
+	t.Run("Single", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "envelopes.pdf")
+		p, err := NewPDFPrinter(Single, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, gifter := range gifters {
+			if _, err := p.Notify(context.Background(), gifter, giftees, email); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+		}
+		if got := checkPDF(t, path); got != len(gifters) {
+			t.Errorf("printed %d pages, want %d", got, len(gifters))
+		}
+
+		// A second run prints to a new file and keeps the first
+		again, err := NewPDFPrinter(Single, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := again.Notify(context.Background(), gifters[0], giftees, email)
+		if err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+		if want := filepath.Join(filepath.Dir(path), "envelopes-2.pdf"); got != want {
+			t.Errorf("second run printed to %s, want %s", got, want)
+		}
+		if got := checkPDF(t, path); got != len(gifters) {
+			t.Errorf("first run's pdf has %d pages after a second run, want %d", got, len(gifters))
+		}
+	})
+
+	t.Run("Separate", func(t *testing.T) {
+		dir := t.TempDir()
+		p, err := NewPDFPrinter(Separate, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, gifter := range gifters {
+			if _, err := p.Notify(context.Background(), gifter, giftees, email); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+		}
+		for _, gifter := range gifters {
+			if got := checkPDF(t, filepath.Join(dir, gifter.Name+".pdf")); got != 1 {
+				t.Errorf("%s.pdf has %d pages, want 1", gifter.Name, got)
+			}
+		}
+	})
+}
+
+func TestWrap(t *testing.T) {
+	text := "Fred's wishlist:\n  - Bowling ball https://example.com/" + strings.Repeat("x", 200)
+	for _, line := range wrap(regular, 12, 200, text) {
+		if w := textWidth(regular, 12, line); w > 200 {
+			t.Errorf("line %q is %.0f wide, want at most 200", line, w)
+		}
+	}
+	if got := escape("(Fred) \\ café €"); got != `\(Fred\) \\ caf\351 \200` {
+		t.Errorf("escape() = %q", got)
+	}
+}
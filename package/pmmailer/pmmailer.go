@@ -0,0 +1,122 @@
+package pmmailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+const DefaultBaseURL = "https://api.postmarkapp.com"
+
+// PostmarkEmailer sends through the Postmark email api.
+type PostmarkEmailer struct {
+	serverToken string
+	baseURL     string
+	client      *http.Client
+}
+
+// NewPostmarkEmailer returns an emailer for the api at baseURL, or
+// Postmark itself when baseURL is empty.
+func NewPostmarkEmailer(serverToken, baseURL string) *PostmarkEmailer {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &PostmarkEmailer{
+		serverToken: serverToken,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type attachment struct {
+	Name        string
+	Content     string
+	ContentType string
+}
+
+type header struct {
+	Name  string
+	Value string
+}
+
+type message struct {
+	From          string
+	To            string
+	Subject       string
+	TextBody      string
+	MessageStream string
+	Attachments   []attachment `json:",omitempty"`
+	Headers       []header     `json:",omitempty"`
+}
+
+type response struct {
+	ErrorCode int
+	Message   string
+	MessageID string
+}
+
+func (m *PostmarkEmailer) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering email: %v", err)
+	}
+	from := mail.Address{Name: emailTemplate.SenderName, Address: emailTemplate.SenderEmail}
+	to := mail.Address{Name: gifter.Name, Address: gifter.Email}
+	msg := message{
+		From:          from.String(),
+		To:            to.String(),
+		Subject:       emailTemplate.Subject,
+		TextBody:      body,
+		MessageStream: "outbound",
+	}
+	if id := send.MessageID(ctx, emailTemplate.SenderEmail); id != "" {
+		msg.Headers = []header{{Name: "Message-ID", Value: id}}
+	}
+	for _, a := range emailTemplate.Attachments {
+		msg.Attachments = append(msg.Attachments, attachment{
+			Name:        a.Filename,
+			Content:     base64.StdEncoding.EncodeToString(a.Data),
+			ContentType: a.ContentType,
+		})
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("error encoding email: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/email", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("X-Postmark-Server-Token", m.serverToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending email with postmark: %v", err)
+	}
+	defer resp.Body.Close()
+	var result response
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("error reading postmark response: %v", err)
+	}
+	if err := json.Unmarshal(raw, &result); err != nil && resp.StatusCode/100 == 2 {
+		return "", fmt.Errorf("error decoding postmark response: %v", err)
+	}
+	if resp.StatusCode/100 != 2 || result.ErrorCode != 0 {
+		return "", fmt.Errorf("postmark returned %s: %d %s", resp.Status, result.ErrorCode, result.Message)
+	}
+	slog.Debug("email accepted", "provider", "postmark", "id", result.MessageID, "response", result.Message)
+	return result.MessageID, nil
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dcmcand/go-secret-santa/package/discordnotifier"
+	"github.com/dcmcand/go-secret-santa/package/filemailer"
+	"github.com/dcmcand/go-secret-santa/package/matrixnotifier"
+	"github.com/dcmcand/go-secret-santa/package/mattermostnotifier"
+	"github.com/dcmcand/go-secret-santa/package/mgmailer"
+	"github.com/dcmcand/go-secret-santa/package/pdfprinter"
+	"github.com/dcmcand/go-secret-santa/package/pmmailer"
+	"github.com/dcmcand/go-secret-santa/package/send"
+	"github.com/dcmcand/go-secret-santa/package/sesmailer"
+	"github.com/dcmcand/go-secret-santa/package/sgmailer"
+	"github.com/dcmcand/go-secret-santa/package/slacknotifier"
+	"github.com/dcmcand/go-secret-santa/package/twilionotifier"
+)
+
+// Config holds the settings a provider is built from. Each provider
+// reads its own section of the config file into it.
+type Config struct {
+	// APIKey is the api key, server token or access key id.
+	APIKey string
+	// Secret is the secret access key for providers that sign requests.
+	Secret string
+	Region string
+	Domain string
+	// BaseURL overrides the provider's api endpoint, e.g. for a regional
+	// endpoint or a local stand-in. For mattermost it is the server and
+	// for matrix the homeserver.
+	BaseURL string
+	// From is the phone number or sender id texts are sent from.
+	From string
+	// MaxSegments caps the length of a text, zero means one segment.
+	MaxSegments int
+	// Format and Path say how and where the file and pdf providers write
+	// messages.
+	Format string
+	Path   string
+}
+
+// Factory builds a Notifier from its config.
+type Factory func(c Config) (send.Notifier, error)
+
+// Email is the channel shared by every email provider. Other providers are
+// channels of their own.
+const Email = "email"
+
+type entry struct {
+	channel string
+	factory Factory
+}
+
+var (
+	registry = map[string]entry{}
+	// order remembers the order providers were registered in.
+	order []string
+)
+
+// Register makes a provider for channel available under name. It panics if
+// the name is already taken.
+func Register(name, channel string, f Factory) {
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("provider %q registered twice", name))
+	}
+	registry[name] = entry{channel: channel, factory: f}
+	order = append(order, name)
+}
+
+// New builds the named provider.
+func New(name string, c Config) (send.Notifier, error) {
+	e, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q, choose one of %s", name, strings.Join(Names(), ", "))
+	}
+	return e.factory(c)
+}
+
+// Names lists the registered providers in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// EmailProviders lists the providers that send email.
+func EmailProviders() []string {
+	var names []string
+	for _, name := range Names() {
+		if registry[name].channel == Email {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// DefaultProvider is the provider used for a channel when the config file
+// doesn't name one: the first registered for it.
+func DefaultProvider(channel string) string {
+	for _, name := range order {
+		if registry[name].channel == channel {
+			return name
+		}
+	}
+	return ""
+}
+
+// Channels lists every way a participant can be notified: email, each
+// chat or messaging provider and printed envelopes.
+func Channels() []string {
+	channels := []string{Email}
+	for _, name := range Names() {
+		if c := registry[name].channel; !slices.Contains(channels, c) {
+			channels = append(channels, c)
+		}
+	}
+	return channels
+}
+
+func init() {
+	Register("mailgun", Email, func(c Config) (send.Notifier, error) {
+		if c.APIKey == "" {
+			return nil, fmt.Errorf("mailgun needs an api key")
+		}
+		if c.Domain == "" {
+			return nil, fmt.Errorf("mailgun needs a sending domain")
+		}
+		return mgmailer.NewMailgunEmailer(c.Domain, c.APIKey, c.BaseURL), nil
+	})
+	Register("sendgrid", Email, func(c Config) (send.Notifier, error) {
+		if c.APIKey == "" {
+			return nil, fmt.Errorf("sendgrid needs an api key")
+		}
+		return sgmailer.NewSendGridEmailer(c.APIKey, c.BaseURL), nil
+	})
+	Register("postmark", Email, func(c Config) (send.Notifier, error) {
+		if c.APIKey == "" {
+			return nil, fmt.Errorf("postmark needs a server token as its api key")
+		}
+		return pmmailer.NewPostmarkEmailer(c.APIKey, c.BaseURL), nil
+	})
+	Register("ses", Email, func(c Config) (send.Notifier, error) {
+		if c.APIKey == "" || c.Secret == "" {
+			return nil, fmt.Errorf("ses needs an access key id as its api key and a secret")
+		}
+		return sesmailer.NewSESEmailer(c.APIKey, c.Secret, c.Region, c.BaseURL), nil
+	})
+	Register("file", Email, func(c Config) (send.Notifier, error) {
+		return filemailer.NewFileEmailer(c.Format, c.Path)
+	})
+	Register("pdf", "print", func(c Config) (send.Notifier, error) {
+		return pdfprinter.NewPDFPrinter(c.Format, c.Path)
+	})
+	Register("slack", "slack", func(c Config) (send.Notifier, error) {
+		if c.APIKey == "" {
+			return nil, fmt.Errorf("slack needs a bot token as its api key")
+		}
+		return slacknotifier.NewSlackNotifier(c.APIKey, c.BaseURL), nil
+	})
+	Register("discord", "discord", func(c Config) (send.Notifier, error) {
+		if c.APIKey == "" {
+			return nil, fmt.Errorf("discord needs a bot token as its api key")
+		}
+		return discordnotifier.NewDiscordNotifier(c.APIKey, c.BaseURL), nil
+	})
+	Register("mattermost", "mattermost", func(c Config) (send.Notifier, error) {
+		if c.BaseURL == "" || c.APIKey == "" {
+			return nil, fmt.Errorf("mattermost needs the server as its base url and a bot access token as its api key")
+		}
+		return mattermostnotifier.NewMattermostNotifier(c.BaseURL, c.APIKey), nil
+	})
+	Register("matrix", "matrix", func(c Config) (send.Notifier, error) {
+		if c.BaseURL == "" || c.APIKey == "" {
+			return nil, fmt.Errorf("matrix needs a homeserver as its base url and an access token as its api key")
+		}
+		return matrixnotifier.NewMatrixNotifier(c.BaseURL, c.APIKey), nil
+	})
+	Register("twilio", "sms", func(c Config) (send.Notifier, error) {
+		if c.APIKey == "" || c.Secret == "" || c.From == "" {
+			return nil, fmt.Errorf("twilio needs an account sid as its api key, an auth token as its secret and a from number")
+		}
+		n := twilionotifier.NewTwilioNotifier(c.APIKey, c.Secret, c.From, c.BaseURL)
+		if c.MaxSegments > 0 {
+			n.MaxSegments = c.MaxSegments
+		}
+		return n, nil
+	})
+}
@@ -0,0 +1,276 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+func TestProviders(t *testing.T) {
+	gifter := send.Participant{Name: "Fred", Email: "fred@bedrock.com"}
+	giftee := send.Participant{Name: "Wilma", Email: "wilma@bedrock.com"}
+	email := &send.Email{
+		Subject:     "Secret Santa",
+		SenderName:  "Santa Claus",
+		SenderEmail: "santa@bedrock.com",
+		Body:        template.Must(template.New("test").Parse("You are buying for {{.Giftee.Name}}")),
+	}
+	tests := []struct {
+		name     string
+		config   Config
+		path     string
+		header   string
+		want     string
+		response string
+	}{
+		{
+			name:     "sendgrid",
+			config:   Config{APIKey: "sg-key"},
+			path:     "/v3/mail/send",
+			header:   "Authorization",
+			want:     "Bearer sg-key",
+			response: "",
+		},
+		{
+			name:     "postmark",
+			config:   Config{APIKey: "pm-token"},
+			path:     "/email",
+			header:   "X-Postmark-Server-Token",
+			want:     "pm-token",
+			response: `{"ErrorCode":0,"Message":"OK","MessageID":"abc"}`,
+		},
+		{
+			name:     "ses",
+			config:   Config{APIKey: "AKID", Secret: "secret", Region: "eu-west-1"},
+			path:     "/v2/email/outbound-emails",
+			header:   "Authorization",
+			want:     "AWS4-HMAC-SHA256 Credential=AKID/",
+			response: `{"MessageId":"abc"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != tt.path {
+					t.Errorf("got %s %s, want POST %s", r.Method, r.URL.Path, tt.path)
+				}
+				if got := r.Header.Get(tt.header); !strings.HasPrefix(got, tt.want) {
+					t.Errorf("%s header = %q, want prefix %q", tt.header, got, tt.want)
+				}
+				raw, _ := io.ReadAll(r.Body)
+				body = string(raw)
+				w.WriteHeader(http.StatusAccepted)
+				io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			tt.config.BaseURL = server.URL
+			emailer, err := New(tt.name, tt.config)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if _, err := emailer.Notify(context.Background(), gifter, []send.Participant{giftee}, email); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			for _, want := range []string{"fred@bedrock.com", "santa@bedrock.com", "You are buying for Wilma"} {
+				if !strings.Contains(body, want) {
+					t.Errorf("request body %s doesn't contain %q", body, want)
+				}
+			}
+		})
+	}
+
+	t.Run("attachments are sent", func(t *testing.T) {
+		ics := []byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n")
+		withInvite := *email
+		withInvite.Attachments = []send.Attachment{{Filename: "invite.ics", ContentType: "text/calendar", Data: ics}}
+		for _, name := range []string{"sendgrid", "postmark", "ses", "mailgun"} {
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				io.WriteString(w, `{}`)
+			}))
+			baseURL := server.URL
+			if name == "mailgun" {
+				baseURL += "/v3"
+			}
+			emailer, err := New(name, Config{APIKey: "key", Secret: "secret", Domain: "bedrock.com", BaseURL: baseURL})
+			if err != nil {
+				t.Fatalf("New(%s) error = %v", name, err)
+			}
+			if _, err := emailer.Notify(context.Background(), gifter, []send.Participant{giftee}, &withInvite); err != nil {
+				t.Fatalf("%s Notify() error = %v", name, err)
+			}
+			server.Close()
+			if name == "ses" {
+				// SES gets the whole MIME message
+				var msg struct {
+					Content struct{ Raw struct{ Data []byte } }
+				}
+				if err := json.Unmarshal(body, &msg); err != nil {
+					t.Fatal(err)
+				}
+				body = msg.Content.Raw.Data
+			}
+			for _, want := range []string{"invite.ics", "text/calendar", base64.StdEncoding.EncodeToString(ics)} {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("%s request %s doesn't contain %q", name, body, want)
+				}
+			}
+		}
+	})
+
+	t.Run("errors are returned", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message":"bad key"}`, http.StatusUnauthorized)
+		}))
+		defer server.Close()
+		for _, name := range []string{"sendgrid", "postmark", "ses"} {
+			emailer, err := New(name, Config{APIKey: "key", Secret: "secret", BaseURL: server.URL})
+			if err != nil {
+				t.Fatalf("New(%s) error = %v", name, err)
+			}
+			if _, err := emailer.Notify(context.Background(), gifter, []send.Participant{giftee}, email); err == nil {
+				t.Errorf("%s Notify() error = nil, want an error", name)
+			}
+		}
+	})
+
+	if _, err := New("pigeon", Config{}); err == nil {
+		t.Errorf("New() with an unknown provider should return an error")
+	}
+}
+
+func TestChatProviders(t *testing.T) {
+	gifter := send.Participant{Name: "Fred", Email: "fred@bedrock.com"}
+	giftee := send.Participant{Name: "Wilma"}
+	email := &send.Email{
+		Subject: "Secret Santa",
+		Body:    template.Must(template.New("test").Parse("You are buying for {{.Giftee.Name}}")),
+	}
+	tests := []struct {
+		name      string
+		config    Config
+		handle    string
+		responses map[string]string
+		message   string
+		// wantID is the message id Notify returns.
+		wantID string
+	}{
+		{
+			name:   "slack",
+			config: Config{APIKey: "xoxb"},
+			handle: "U123",
+			responses: map[string]string{
+				"/api/conversations.open": `{"ok":true,"channel":{"id":"D1"}}`,
+				"/api/chat.postMessage":   `{"ok":true,"ts":"1.2"}`,
+			},
+			message: "/api/chat.postMessage",
+			wantID:  "1.2",
+		},
+		{
+			name:   "slack without a handle looks up the email",
+			config: Config{APIKey: "xoxb"},
+			responses: map[string]string{
+				"/api/users.lookupByEmail": `{"ok":true,"user":{"id":"U123"}}`,
+				"/api/conversations.open":  `{"ok":true,"channel":{"id":"D1"}}`,
+				"/api/chat.postMessage":    `{"ok":true,"ts":"1.2"}`,
+			},
+			message: "/api/chat.postMessage",
+		},
+		{
+			name:   "discord",
+			config: Config{APIKey: "bot"},
+			handle: "42",
+			responses: map[string]string{
+				"/api/v10/users/@me/channels":  `{"id":"7"}`,
+				"/api/v10/channels/7/messages": `{"id":"8"}`,
+			},
+			message: "/api/v10/channels/7/messages",
+		},
+		{
+			name:   "mattermost",
+			config: Config{APIKey: "bot-token"},
+			handle: "fred",
+			responses: map[string]string{
+				"/api/v4/users/me":            `{"id":"bot"}`,
+				"/api/v4/users/username/fred": `{"id":"u1"}`,
+				"/api/v4/channels/direct":     `{"id":"c1"}`,
+				"/api/v4/posts":               `{"id":"p1"}`,
+			},
+			message: "/api/v4/posts",
+			wantID:  "p1",
+		},
+		{
+			name:   "matrix reuses the direct room",
+			config: Config{APIKey: "token"},
+			handle: "@fred:bedrock.org",
+			responses: map[string]string{
+				"/_matrix/client/v3/account/whoami":                                `{"user_id":"@santa:bedrock.org"}`,
+				"/_matrix/client/v3/user/@santa:bedrock.org/account_data/m.direct": `{"@fred:bedrock.org":["!old:bedrock.org","!room:bedrock.org"]}`,
+				"/_matrix/client/v3/rooms/!room:bedrock.org/send/m.room.message/":  `{"event_id":"$1"}`,
+			},
+			message: "/_matrix/client/v3/rooms/!room:bedrock.org/send/m.room.message/",
+			wantID:  "$1",
+		},
+		{
+			name:   "matrix creates a direct room",
+			config: Config{APIKey: "token"},
+			handle: "@fred:bedrock.org",
+			responses: map[string]string{
+				"/_matrix/client/v3/account/whoami":                                `{"user_id":"@santa:bedrock.org"}`,
+				"/_matrix/client/v3/user/@santa:bedrock.org/account_data/m.direct": `{}`,
+				"/_matrix/client/v3/createRoom":                                    `{"room_id":"!room:bedrock.org"}`,
+				"/_matrix/client/v3/rooms/!room:bedrock.org/send/m.room.message/":  `{"event_id":"$1"}`,
+			},
+			message: "/_matrix/client/v3/rooms/!room:bedrock.org/send/m.room.message/",
+			wantID:  "$1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var message string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for path, response := range tt.responses {
+					if r.URL.Path == path || (strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path)) {
+						if path == tt.message {
+							raw, _ := io.ReadAll(r.Body)
+							message = string(raw)
+						}
+						io.WriteString(w, response)
+						return
+					}
+				}
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				http.NotFound(w, r)
+			}))
+			defer server.Close()
+
+			tt.config.BaseURL = server.URL
+			notifier, err := New(strings.Fields(tt.name)[0], tt.config)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			gifter.Handle = tt.handle
+			id, err := notifier.Notify(context.Background(), gifter, []send.Participant{giftee}, email)
+			if err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			if tt.wantID != "" && id != tt.wantID {
+				t.Errorf("Notify() id = %q, want %q", id, tt.wantID)
+			}
+			if !strings.Contains(message, "You are buying for Wilma") {
+				t.Errorf("message %q doesn't contain the assignment", message)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// Notifier opens a conversation with each giftee as assignments are sent,
+// and gives the assignment template a link to it through relayLink.
+type Notifier struct {
+	Next  send.Notifier
+	Relay *Relay
+}
+
+func (n *Notifier) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	links := make(map[string]string, len(giftees))
+	for _, giftee := range giftees {
+		link, err := n.Relay.Start(send.DrawID(ctx), gifter, giftee)
+		if err != nil {
+			return "", err
+		}
+		links[giftee.Name] = link
+	}
+	tmpl, err := emailTemplate.Body.Clone()
+	if err != nil {
+		return "", fmt.Errorf("error rendering email: %v", err)
+	}
+	tmpl.Funcs(template.FuncMap{"relayLink": func(giftee string) string { return links[giftee] }})
+	msg := *emailTemplate
+	msg.Body = tmpl
+	return n.Next.Notify(ctx, gifter, giftees, &msg)
+}
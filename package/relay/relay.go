@@ -0,0 +1,249 @@
+package relay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dcmcand/go-secret-santa/package/filestore"
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// Sides of a conversation.
+const (
+	Gifter = "gifter"
+	Giftee = "giftee"
+)
+
+// MaxMessageLength is the longest message that is relayed, in characters.
+const MaxMessageLength = 2000
+
+var (
+	// ErrInvalid is returned for a link that wasn't made by this relay.
+	ErrInvalid = errors.New("this link isn't valid")
+	// ErrClosed is returned once a conversation's pair is no longer in
+	// the draw.
+	ErrClosed = errors.New("this conversation has been closed")
+	// ErrEmpty is returned for a message with nothing in it.
+	ErrEmpty = errors.New("the message is empty")
+	// ErrTooLong is returned for a message over MaxMessageLength.
+	ErrTooLong = fmt.Errorf("messages can be at most %d characters", MaxMessageLength)
+)
+
+// Message is one relayed message.
+type Message struct {
+	From string    `json:"from"`
+	Text string    `json:"text"`
+	Sent time.Time `json:"sent"`
+}
+
+// Conversation is between a gifter and one of their giftees. The giftee
+// only ever sees "your Secret Santa".
+type Conversation struct {
+	ID       string           `json:"id"`
+	Draw     string           `json:"draw,omitempty"`
+	Gifter   send.Participant `json:"gifter"`
+	Giftee   send.Participant `json:"giftee"`
+	Closed   bool             `json:"closed,omitempty"`
+	Messages []Message        `json:"messages,omitempty"`
+}
+
+// Relay passes anonymous messages between gifters and giftees. Each
+// conversation is kept in its own file in a directory, along with the key
+// links are signed with. The links are the only way in, so each side's link
+// must only be sent to them.
+type Relay struct {
+	// BaseURL is where the server relaying messages is reached.
+	BaseURL string
+	// Template is the message telling someone they have a message. Its
+	// body can call the functions in template.RelayMessageFuncs.
+	Template *send.Email
+	// Deliver sends a message to a participant, see send.Sender.Message.
+	Deliver func(ctx context.Context, to send.Participant, message *send.Email) (string, error)
+
+	dir string
+	key []byte
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// Open opens the relay in dir, creating it and its signing key if needed.
+func Open(dir string) (*Relay, error) {
+	key, err := filestore.SigningKey(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening relay: %v", err)
+	}
+	return &Relay{dir: dir, key: key, now: time.Now}, nil
+}
+
+// conversationID is stable for a pair in a draw, so sending an assignment
+// again keeps the conversation going.
+func conversationID(draw, gifter, giftee string) string {
+	sum := sha256.Sum256([]byte(draw + "\x00" + gifter + "\x00" + giftee))
+	return hex.EncodeToString(sum[:12])
+}
+
+// Start opens the conversation between a gifter and giftee, or updates
+// their details if it is already open, and returns the gifter's link.
+func (r *Relay) Start(draw string, gifter, giftee send.Participant) (string, error) {
+	id := conversationID(draw, gifter.Name, giftee.Name)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, err := r.load(id)
+	if errors.Is(err, ErrInvalid) {
+		c, err = Conversation{ID: id, Draw: draw}, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	c.Gifter, c.Giftee, c.Closed = gifter, giftee, false
+	if err := r.save(c); err != nil {
+		return "", err
+	}
+	return r.link(id, Gifter), nil
+}
+
+// Close ends the conversation between a gifter and giftee, for example
+// when one of them drops out.
+func (r *Relay) Close(draw, gifter, giftee string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, err := r.load(conversationID(draw, gifter, giftee))
+	if errors.Is(err, ErrInvalid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.Closed = true
+	return r.save(c)
+}
+
+func (r *Relay) link(id, side string) string {
+	payload := id + "." + side
+	return strings.TrimSuffix(r.BaseURL, "/") + "/relay/" + payload + "." + r.sign(payload)
+}
+
+func (r *Relay) sign(payload string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:18])
+}
+
+// Lookup returns the conversation for a link's token and which side of it
+// the link belongs to.
+func (r *Relay) Lookup(token string) (Conversation, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.check(token)
+}
+
+func (r *Relay) check(token string) (Conversation, string, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(r.sign(token[:i]))) {
+		return Conversation{}, "", ErrInvalid
+	}
+	id, side, _ := strings.Cut(token[:i], ".")
+	if side != Gifter && side != Giftee {
+		return Conversation{}, "", ErrInvalid
+	}
+	c, err := r.load(id)
+	if err != nil {
+		return Conversation{}, "", err
+	}
+	if c.Closed {
+		return Conversation{}, "", ErrClosed
+	}
+	return c, side, nil
+}
+
+// Send relays a message from the side of the conversation the token
+// belongs to, and returns the conversation with it added.
+func (r *Relay) Send(ctx context.Context, token, text string) (Conversation, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Conversation{}, ErrEmpty
+	}
+	if utf8.RuneCountInString(text) > MaxMessageLength {
+		return Conversation{}, ErrTooLong
+	}
+	r.mu.Lock()
+	c, side, err := r.check(token)
+	r.mu.Unlock()
+	if err != nil {
+		return Conversation{}, err
+	}
+
+	to, from, reply := c.Giftee, "Your Secret Santa", r.link(c.ID, Giftee)
+	if side == Giftee {
+		to, from, reply = c.Gifter, c.Giftee.Name+", who you're buying for,", r.link(c.ID, Gifter)
+	}
+	tmpl, err := r.Template.Body.Clone()
+	if err != nil {
+		return Conversation{}, fmt.Errorf("error rendering message: %v", err)
+	}
+	tmpl.Funcs(template.FuncMap{
+		"relayFrom":    func() string { return from },
+		"relayMessage": func() string { return text },
+		"relayReply":   func() string { return reply },
+	})
+	msg := *r.Template
+	msg.Body = tmpl
+	if _, err := r.Deliver(ctx, to, &msg); err != nil {
+		return Conversation{}, fmt.Errorf("error relaying message: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Reload in case the other side wrote while this was being sent
+	if c, err = r.load(c.ID); err != nil {
+		return Conversation{}, err
+	}
+	c.Messages = append(c.Messages, Message{From: side, Text: text, Sent: r.now().UTC().Truncate(time.Second)})
+	return c, r.save(c)
+}
+
+var validID = regexp.MustCompile(`^[0-9a-f]{24}$`)
+
+func (r *Relay) load(id string) (Conversation, error) {
+	if !validID.MatchString(id) {
+		return Conversation{}, ErrInvalid
+	}
+	data, err := os.ReadFile(filepath.Join(r.dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Conversation{}, ErrInvalid
+	}
+	if err != nil {
+		return Conversation{}, fmt.Errorf("error reading conversation: %v", err)
+	}
+	var c Conversation
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Conversation{}, fmt.Errorf("error reading conversation %s: %v", id, err)
+	}
+	return c, nil
+}
+
+// save writes the conversation so it is never read half written.
+func (r *Relay) save(c Conversation) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding conversation: %v", err)
+	}
+	if err := filestore.WriteFile(filepath.Join(r.dir, c.ID+".json"), data); err != nil {
+		return fmt.Errorf("error saving conversation: %v", err)
+	}
+	return nil
+}
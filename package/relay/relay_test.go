@@ -0,0 +1,143 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+type delivery struct {
+	to   string
+	body string
+}
+
+func newTestRelay(t *testing.T, sent *[]delivery) *Relay {
+	t.Helper()
+	r, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	r.BaseURL = "https://santa.example.com/"
+	r.Template = &send.Email{Body: template.Must(template.New("relay").Funcs(template.FuncMap{
+		"relayFrom":    func() string { return "" },
+		"relayMessage": func() string { return "" },
+		"relayReply":   func() string { return "" },
+	}).Parse("{{relayFrom}} said {{relayMessage}}, reply at {{relayReply}}"))}
+	r.Deliver = func(ctx context.Context, to send.Participant, message *send.Email) (string, error) {
+		body, err := message.Render(to)
+		*sent = append(*sent, delivery{to: to.Email, body: body})
+		return "id", err
+	}
+	return r
+}
+
+var linkToken = regexp.MustCompile(`/relay/(\S+)`)
+
+func TestRelay_Send(t *testing.T) {
+	var sent []delivery
+	r := newTestRelay(t, &sent)
+	fred := send.Participant{Name: "Fred", Email: "fred@example.com"}
+	barney := send.Participant{Name: "Barney", Email: "barney@example.com"}
+	link, err := r.Start("draw", fred, barney)
+	if err != nil {
+		t.Fatalf("Relay.Start() error = %v", err)
+	}
+	if !strings.HasPrefix(link, "https://santa.example.com/relay/") {
+		t.Fatalf("Relay.Start() = %q", link)
+	}
+	gifterToken := strings.TrimPrefix(link, "https://santa.example.com/relay/")
+
+	if _, err := r.Send(context.Background(), gifterToken, "  "); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Relay.Send() of an empty message error = %v, want ErrEmpty", err)
+	}
+	if _, err := r.Send(context.Background(), gifterToken, strings.Repeat("x", MaxMessageLength+1)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Relay.Send() of a long message error = %v, want ErrTooLong", err)
+	}
+	if _, err := r.Send(context.Background(), gifterToken+"x", "hi"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Relay.Send() with a tampered token error = %v, want ErrInvalid", err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("invalid messages were delivered: %v", sent)
+	}
+
+	c, err := r.Send(context.Background(), gifterToken, "What size are you?")
+	if err != nil {
+		t.Fatalf("Relay.Send() error = %v", err)
+	}
+	if len(sent) != 1 || sent[0].to != barney.Email || !strings.Contains(sent[0].body, "Your Secret Santa said What size are you?") {
+		t.Fatalf("Relay.Send() delivered %v", sent)
+	}
+	if strings.Contains(sent[0].body, "Fred") {
+		t.Errorf("the gifter was named to the giftee: %s", sent[0].body)
+	}
+	if len(c.Messages) != 1 || c.Messages[0].From != Gifter {
+		t.Errorf("Relay.Send() = %+v", c)
+	}
+
+	// Replying from the link in the message goes back to the gifter
+	m := linkToken.FindStringSubmatch(sent[0].body)
+	if m == nil {
+		t.Fatalf("no reply link in %q", sent[0].body)
+	}
+	if _, side, err := r.Lookup(m[1]); err != nil || side != Giftee {
+		t.Fatalf("Relay.Lookup() of the reply link = %q, %v", side, err)
+	}
+	c, err = r.Send(context.Background(), m[1], "Large")
+	if err != nil {
+		t.Fatalf("Relay.Send() of a reply error = %v", err)
+	}
+	if len(sent) != 2 || sent[1].to != fred.Email || !strings.Contains(sent[1].body, "Barney, who you're buying for, said Large") {
+		t.Errorf("Relay.Send() of a reply delivered %v", sent[1:])
+	}
+	if len(c.Messages) != 2 || c.Messages[1].From != Giftee {
+		t.Errorf("Relay.Send() of a reply = %+v", c)
+	}
+
+	// Sending the assignment again keeps the conversation
+	if again, _ := r.Start("draw", fred, barney); again != link {
+		t.Errorf("Relay.Start() again = %q, want %q", again, link)
+	}
+	if err := r.Close("draw", "Fred", "Barney"); err != nil {
+		t.Fatalf("Relay.Close() error = %v", err)
+	}
+	if _, err := r.Send(context.Background(), m[1], "hello?"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Relay.Send() after Close() error = %v, want ErrClosed", err)
+	}
+}
+
+type testNotifier struct {
+	body *string
+}
+
+func (n testNotifier) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	*n.body = body
+	return "id", err
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	var sent []delivery
+	r := newTestRelay(t, &sent)
+	var body string
+	n := &Notifier{Next: testNotifier{body: &body}, Relay: r}
+	tmpl := &send.Email{Body: template.Must(template.New("assignment").Funcs(template.FuncMap{
+		"relayLink": func(string) string { return "" },
+	}).Parse("{{range .Giftees}}{{.Name}}: {{relayLink .Name}}\n{{end}}"))}
+
+	fred := send.Participant{Name: "Fred"}
+	giftees := []send.Participant{{Name: "Barney"}, {Name: "Wilma"}}
+	if _, err := n.Notify(context.Background(), fred, giftees, tmpl); err != nil {
+		t.Fatalf("Notifier.Notify() error = %v", err)
+	}
+	for _, giftee := range giftees {
+		link, _ := r.Start("", fred, giftee)
+		if !strings.Contains(body, giftee.Name+": "+link+"\n") {
+			t.Errorf("Notifier.Notify() sent %q, want %s's link %s", body, giftee.Name, link)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package reveal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// Notifier sends each gifter a link to a page showing their assignment
+// instead of the assignment itself, so there is nothing in their inbox to
+// forward or screenshot. The rendered assignment is kept in Store until
+// the link is opened.
+type Notifier struct {
+	Next  send.Notifier
+	Store *Store
+	// BaseURL is where the server revealing assignments is reached.
+	BaseURL string
+	// Expires is how long links work for.
+	Expires time.Duration
+	// MaxViews is how many times a link can be opened. Zero means once.
+	MaxViews int
+	// Template is the message carrying the link. Its body can call
+	// revealLink and revealExpires, see template.RevealFuncs.
+	Template *send.Email
+}
+
+func (n *Notifier) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering assignment: %v", err)
+	}
+	expires := n.Store.now().Add(n.Expires)
+	token, err := n.Store.Add(Assignment{
+		ID:       assignmentID(ctx, gifter.Name),
+		Draw:     send.DrawID(ctx),
+		Gifter:   gifter.Name,
+		Subject:  emailTemplate.Subject,
+		Body:     body,
+		Expires:  expires,
+		MaxViews: n.MaxViews,
+	})
+	if err != nil {
+		return "", err
+	}
+	link := strings.TrimSuffix(n.BaseURL, "/") + "/reveal/" + token
+
+	tmpl, err := n.Template.Body.Clone()
+	if err != nil {
+		return "", fmt.Errorf("error rendering link: %v", err)
+	}
+	tmpl.Funcs(template.FuncMap{
+		"revealLink":    func() string { return link },
+		"revealExpires": func() string { return expires.Format("Monday 2 January") },
+	})
+	msg := *n.Template
+	msg.Body = tmpl
+	return n.Next.Notify(ctx, gifter, nil, &msg)
+}
+
+// assignmentID is the same each time a gifter is sent their assignment from
+// a draw, so sending it again, even after it changed, replaces its page.
+func assignmentID(ctx context.Context, gifter string) string {
+	draw := send.DrawID(ctx)
+	if draw == "" {
+		return send.IdempotencyKey(ctx)
+	}
+	sum := sha256.Sum256([]byte(draw + "\x00" + gifter))
+	return hex.EncodeToString(sum[:12])
+}
@@ -0,0 +1,209 @@
+package reveal
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/filestore"
+)
+
+var (
+	// ErrInvalid is returned for a link that wasn't made by this store.
+	ErrInvalid = errors.New("this link isn't valid")
+	// ErrExpired is returned for a link past its expiry.
+	ErrExpired = errors.New("this link has expired")
+	// ErrUsedUp is returned once a link has been viewed as many times as
+	// it allows.
+	ErrUsedUp = errors.New("this link has already been viewed as many times as it allows")
+)
+
+// Assignment is a rendered assignment waiting to be revealed.
+type Assignment struct {
+	ID string `json:"id"`
+	// Draw is the id of the draw the assignment is from, if it was saved.
+	Draw     string      `json:"draw,omitempty"`
+	Gifter   string      `json:"gifter"`
+	Subject  string      `json:"subject"`
+	Body     string      `json:"body"`
+	Created  time.Time   `json:"created"`
+	Expires  time.Time   `json:"expires"`
+	MaxViews int         `json:"max_views"`
+	Views    []time.Time `json:"views,omitempty"`
+}
+
+// ViewsLeft is how many more times the assignment can be viewed.
+func (a Assignment) ViewsLeft() int {
+	return max(a.MaxViews-len(a.Views), 0)
+}
+
+// Seen reports whether the gifter has opened their link.
+func (a Assignment) Seen() bool {
+	return len(a.Views) > 0
+}
+
+// Store keeps each assignment in its own file in a directory, so the
+// command sending links and the server revealing them can share it. The
+// directory also holds the key links are signed with.
+type Store struct {
+	dir string
+	key []byte
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// Open opens the store in dir, creating it and its signing key if needed.
+func Open(dir string) (*Store, error) {
+	key, err := filestore.SigningKey(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening reveal store: %v", err)
+	}
+	return &Store{dir: dir, key: key, now: time.Now}, nil
+}
+
+var validID = regexp.MustCompile(`^[0-9a-f]{16,64}$`)
+
+// Add saves an assignment and returns the token for its link. An empty ID
+// is filled in with a random one, and adding the same ID again replaces
+// the assignment and resets its views.
+func (s *Store) Add(a Assignment) (string, error) {
+	if a.ID == "" {
+		id := make([]byte, 12)
+		rand.Read(id)
+		a.ID = hex.EncodeToString(id)
+	}
+	if !validID.MatchString(a.ID) {
+		return "", fmt.Errorf("invalid assignment id %q", a.ID)
+	}
+	if a.Created.IsZero() {
+		a.Created = s.now().UTC().Truncate(time.Second)
+	}
+	a.Expires = a.Expires.UTC().Truncate(time.Second)
+	a.MaxViews = max(a.MaxViews, 1)
+	a.Views = nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.save(a); err != nil {
+		return "", err
+	}
+	return s.token(a), nil
+}
+
+// token is the assignment id, its expiry and a signature over both, so a
+// link can't be made for another assignment or given a later expiry.
+func (s *Store) token(a Assignment) string {
+	payload := a.ID + "." + strconv.FormatInt(a.Expires.Unix(), 10)
+	return payload + "." + s.sign(payload)
+}
+
+func (s *Store) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:18])
+}
+
+// Peek checks a token and returns its assignment without counting a view.
+func (s *Store) Peek(token string) (Assignment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.check(token)
+}
+
+// View checks a token and records a view of its assignment.
+func (s *Store) View(token string) (Assignment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.check(token)
+	if err != nil {
+		return a, err
+	}
+	a.Views = append(a.Views, s.now().UTC().Truncate(time.Second))
+	if err := s.save(a); err != nil {
+		return Assignment{}, err
+	}
+	return a, nil
+}
+
+func (s *Store) check(token string) (Assignment, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(s.sign(token[:i]))) {
+		return Assignment{}, ErrInvalid
+	}
+	id, expires, _ := strings.Cut(token[:i], ".")
+	a, err := s.load(id)
+	if err != nil {
+		return Assignment{}, err
+	}
+	if strconv.FormatInt(a.Expires.Unix(), 10) != expires {
+		// The assignment was sent again with a new link
+		return Assignment{}, ErrInvalid
+	}
+	if !s.now().Before(a.Expires) {
+		return Assignment{}, ErrExpired
+	}
+	if a.ViewsLeft() == 0 {
+		return Assignment{}, ErrUsedUp
+	}
+	return a, nil
+}
+
+// Assignments lists everything in the store.
+func (s *Store) Assignments() ([]Assignment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading reveal store: %v", err)
+	}
+	assignments := make([]Assignment, 0, len(paths))
+	for _, path := range paths {
+		a, err := s.load(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, nil
+}
+
+func (s *Store) load(id string) (Assignment, error) {
+	if !validID.MatchString(id) {
+		return Assignment{}, ErrInvalid
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Assignment{}, ErrInvalid
+	}
+	if err != nil {
+		return Assignment{}, fmt.Errorf("error reading assignment: %v", err)
+	}
+	var a Assignment
+	if err := json.Unmarshal(data, &a); err != nil {
+		return Assignment{}, fmt.Errorf("error reading assignment %s: %v", id, err)
+	}
+	return a, nil
+}
+
+// save writes the assignment so the server never reads half of one.
+func (s *Store) save(a Assignment) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding assignment: %v", err)
+	}
+	if err := filestore.WriteFile(filepath.Join(s.dir, a.ID+".json"), data); err != nil {
+		return fmt.Errorf("error saving assignment: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package reveal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+func TestStore_View(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	now := time.Date(2026, 12, 1, 9, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	token, err := s.Add(Assignment{Gifter: "Fred", Body: "You are buying for Barney", Expires: now.Add(time.Hour), MaxViews: 2})
+	if err != nil {
+		t.Fatalf("Store.Add() error = %v", err)
+	}
+
+	tampered := []byte(token)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := s.Peek(string(tampered)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Store.Peek() with a tampered token error = %v, want ErrInvalid", err)
+	}
+	parts := strings.Split(token, ".")
+	if _, err := s.Peek(parts[0] + ".9999999999." + parts[2]); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Store.Peek() with a later expiry error = %v, want ErrInvalid", err)
+	}
+
+	for range 3 {
+		if _, err := s.Peek(token); err != nil {
+			t.Fatalf("Store.Peek() error = %v", err)
+		}
+	}
+	for i := range 2 {
+		a, err := s.View(token)
+		if err != nil {
+			t.Fatalf("Store.View() error = %v", err)
+		}
+		if a.Body != "You are buying for Barney" || !a.Seen() || a.ViewsLeft() != 1-i {
+			t.Errorf("Store.View() = %+v", a)
+		}
+	}
+	if _, err := s.View(token); !errors.Is(err, ErrUsedUp) {
+		t.Errorf("Store.View() after every view error = %v, want ErrUsedUp", err)
+	}
+
+	token, _ = s.Add(Assignment{Gifter: "Wilma", Expires: now.Add(time.Hour)})
+	now = now.Add(time.Hour)
+	if _, err := s.View(token); !errors.Is(err, ErrExpired) {
+		t.Errorf("Store.View() after expiry error = %v, want ErrExpired", err)
+	}
+}
+
+type testNotifier struct {
+	body *string
+}
+
+func (n testNotifier) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	*n.body = body
+	return "id", err
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent string
+	n := &Notifier{
+		Next:    testNotifier{body: &sent},
+		Store:   s,
+		BaseURL: "https://santa.example.com/",
+		Expires: time.Hour,
+		Template: &send.Email{Body: template.Must(template.New("").Funcs(template.FuncMap{"revealLink": func() string { return "" }}).Parse(
+			"{{.Gifter.Name}}: {{revealLink}}"))},
+	}
+	assignment := &send.Email{Subject: "Secret Santa", Body: template.Must(template.New("").Parse("{{.Gifter.Name}} buys for {{.GifteeNames}}"))}
+	ctx := send.WithIdempotencyKey(context.Background(), "0123456789abcdef01234567")
+	if _, err := n.Notify(ctx, send.Participant{Name: "Fred"}, []send.Participant{{Name: "Barney"}}, assignment); err != nil {
+		t.Fatalf("Notifier.Notify() error = %v", err)
+	}
+	link, ok := strings.CutPrefix(sent, "Fred: https://santa.example.com/reveal/")
+	if !ok || strings.Contains(sent, "Barney") {
+		t.Fatalf("sent %q, want only a link", sent)
+	}
+	a, err := s.View(link)
+	if err != nil {
+		t.Fatalf("Store.View() error = %v", err)
+	}
+	if a.ID != "0123456789abcdef01234567" || a.Body != "Fred buys for Barney" || a.Subject != "Secret Santa" {
+		t.Errorf("revealed %+v", a)
+	}
+}
@@ -0,0 +1,199 @@
+// Package santa draws names and delivers assignments for programs that use
+// secret santa as a library instead of through the command line:
+//
+//	assignment, err := santa.Draw(ctx, participants, santa.Constraints{}, santa.DrawOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	// Keep the assignment before sending, so a failed delivery can be
+//	// resumed with the same names
+//	data, err := json.Marshal(assignment)
+//	...
+//	err = santa.Deliver(ctx, assignment, participants, santa.WithNotifier(mailer))
+package santa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+	"github.com/dcmcand/go-secret-santa/package/template"
+)
+
+// Constraints are rules for the draw on top of each participant's Partner
+// and Exclusions.
+type Constraints struct {
+	// Exclusions maps a gifter's name to people they mustn't buy for.
+	Exclusions map[string][]string
+	// Preferences scores possible pairings, and the best scoring draw is
+	// used instead of a uniformly random one.
+	Preferences send.Preferences
+}
+
+// DrawOptions change how names are drawn.
+type DrawOptions struct {
+	// GiftsPerPerson is how many people each participant buys for. Zero
+	// means one.
+	GiftsPerPerson int
+}
+
+// Draw draws names for the participants. The assignment has an id, so it
+// can be stored and passed to Deliver again to resume a delivery.
+func Draw(ctx context.Context, participants []send.Participant, constraints Constraints, opts DrawOptions) (send.Assignment, error) {
+	people, err := index(participants)
+	if err != nil {
+		return send.Assignment{}, err
+	}
+	for gifter, excluded := range constraints.Exclusions {
+		p, ok := people[gifter]
+		if !ok {
+			return send.Assignment{}, fmt.Errorf("exclusions given for %s, who isn't a participant", gifter)
+		}
+		p.Exclusions = append(slices.Clone(p.Exclusions), excluded...)
+		people[gifter] = p
+	}
+	s := &send.Sender{
+		ParticipantLoader: loader(people),
+		GiftsPerPerson:    opts.GiftsPerPerson,
+		Preferences:       constraints.Preferences,
+	}
+	draw, err := s.NewDraw(ctx, "")
+	if err != nil {
+		return send.Assignment{}, err
+	}
+	return draw.Assignment, nil
+}
+
+// Option configures Deliver.
+type Option func(*send.Sender)
+
+// WithNotifier delivers to participants who don't list any channels.
+func WithNotifier(n send.Notifier) Option {
+	return func(s *send.Sender) { s.Notifier = n }
+}
+
+// WithChannel delivers on a channel, such as "email" or "sms", to
+// participants who list it in their Channels.
+func WithChannel(channel string, n send.Notifier) Option {
+	return func(s *send.Sender) {
+		if s.Notifiers == nil {
+			s.Notifiers = map[string]send.Notifier{}
+		}
+		s.Notifiers[channel] = n
+	}
+}
+
+// WithTemplate sets the message sent on a channel, or by the default
+// notifier when channel is "". Without one the built in email template is
+// used.
+func WithTemplate(channel string, e *send.Email) Option {
+	return func(s *send.Sender) {
+		if channel == "" {
+			s.EmailTemplate = e
+			return
+		}
+		if s.Templates == nil {
+			s.Templates = map[string]*send.Email{}
+		}
+		s.Templates[channel] = e
+	}
+}
+
+// WithJournal records each delivery, and skips gifters the journal already
+// has, so an interrupted Deliver can be run again.
+func WithJournal(j *send.Journal) Option {
+	return func(s *send.Sender) { s.Journal = j }
+}
+
+// WithConcurrency sends n messages at once.
+func WithConcurrency(n int) Option {
+	return func(s *send.Sender) { s.Concurrency = n }
+}
+
+// WithRate sends no faster than r.
+func WithRate(r send.Rate) Option {
+	return func(s *send.Sender) { s.Rate = r }
+}
+
+// WithOutput writes a summary of the delivery to w when it finishes: how
+// many assignments were sent and who wasn't sent theirs. It never says who
+// buys for whom.
+func WithOutput(w io.Writer) Option {
+	return func(s *send.Sender) { s.Output = w }
+}
+
+// Deliver sends every participant their assignment. If anything wasn't
+// delivered the error wraps a *send.DeliveryError saying who was and
+// wasn't sent their assignment.
+func Deliver(ctx context.Context, assignment send.Assignment, participants []send.Participant, opts ...Option) error {
+	people, err := index(participants)
+	if err != nil {
+		return err
+	}
+	if len(assignment.Pairs) == 0 {
+		return fmt.Errorf("the assignment is empty, use Draw to make one")
+	}
+	s := &send.Sender{ParticipantLoader: loader(people), Draw: &send.Draw{ID: assignment.Draw, Assignment: assignment}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.Notifier == nil && len(s.Notifiers) == 0 {
+		return fmt.Errorf("nothing to deliver with, use WithNotifier or WithChannel")
+	}
+	if s.EmailTemplate == nil {
+		if s.EmailTemplate, err = template.GetDefaultTemplate("Secret Santa Assignment", "Santa Claus", ""); err != nil {
+			return err
+		}
+	}
+	if s.Output == nil {
+		return s.Send(ctx, "")
+	}
+	sent := 0
+	s.Delivered = func(d send.Delivery, err error) {
+		if err == nil {
+			sent++
+		}
+	}
+	err = s.Send(ctx, "")
+	summarise(s.Output, sent, err)
+	return err
+}
+
+// summarise writes how a delivery went.
+func summarise(w io.Writer, sent int, err error) {
+	fmt.Fprintf(w, "%d assignments sent\n", sent)
+	var report *send.DeliveryError
+	switch {
+	case errors.As(err, &report):
+		fmt.Fprintf(w, "not every assignment was sent: %v\n", report)
+	case err != nil:
+		fmt.Fprintf(w, "delivery failed: %v\n", err)
+	}
+}
+
+func index(participants []send.Participant) (send.Participants, error) {
+	people := make(send.Participants, len(participants))
+	for _, p := range participants {
+		if p.Name == "" {
+			return nil, fmt.Errorf("participant with email %s has no name", p.Email)
+		}
+		if _, ok := people[p.Name]; ok {
+			return nil, fmt.Errorf("%s is listed more than once", p.Name)
+		}
+		people[p.Name] = p
+	}
+	return people, nil
+}
+
+// loader hands participants already in memory to a send.Sender.
+type loader send.Participants
+
+func (l loader) LoadParticipants(ctx context.Context, path string) (send.Participants, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return send.Participants(l), nil
+}
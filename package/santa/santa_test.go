@@ -0,0 +1,122 @@
+package santa
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+var flintstones = []send.Participant{
+	{Name: "Fred", Email: "fred@bedrock.org", Partner: "Wilma"},
+	{Name: "Wilma", Email: "wilma@bedrock.org", Partner: "Fred"},
+	{Name: "Barney", Email: "barney@bedrock.org", Partner: "Betty"},
+	{Name: "Betty", Email: "betty@bedrock.org", Partner: "Barney"},
+}
+
+func TestDraw(t *testing.T) {
+	tests := []struct {
+		name         string
+		participants []send.Participant
+		constraints  Constraints
+		opts         DrawOptions
+		wantErr      bool
+	}{
+		{name: "Partners", participants: flintstones},
+		{name: "Extra exclusions", participants: flintstones, constraints: Constraints{Exclusions: map[string][]string{"Fred": {"Barney"}}}},
+		{name: "The README example", participants: flintstones, constraints: Constraints{Exclusions: map[string][]string{"Barney": {"Fred"}}}},
+		{name: "Several gifts each", participants: append(slices.Clone(flintstones), send.Participant{Name: "Pebbles"}), opts: DrawOptions{GiftsPerPerson: 2}},
+		{name: "Exclusions for a stranger", participants: flintstones, constraints: Constraints{Exclusions: map[string][]string{"Dino": {"Fred"}}}, wantErr: true},
+		{name: "Duplicate names", participants: append(slices.Clone(flintstones), send.Participant{Name: "Fred"}), wantErr: true},
+		{name: "Impossible", participants: flintstones[:2], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignment, err := Draw(context.Background(), tt.participants, tt.constraints, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Draw() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(assignment.Pairs) != len(tt.participants) || assignment.Draw == "" {
+				t.Errorf("Draw() paired %d people in draw %q, want %d with an id", len(assignment.Pairs), assignment.Draw, len(tt.participants))
+			}
+			for _, p := range tt.participants {
+				giftees, _ := assignment.Giftees(p.Name)
+				if len(giftees) != max(tt.opts.GiftsPerPerson, 1) || slices.Contains(giftees, p.Partner) {
+					t.Errorf("%s buys for %v", p.Name, giftees)
+				}
+				for _, excluded := range tt.constraints.Exclusions[p.Name] {
+					if slices.Contains(giftees, excluded) {
+						t.Errorf("%s buys for %s, who they were excluded from", p.Name, excluded)
+					}
+				}
+			}
+		})
+	}
+}
+
+type recorder struct {
+	mu   sync.Mutex
+	sent map[string]string
+	err  error
+}
+
+func (r *recorder) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sent == nil {
+		r.sent = map[string]string{}
+	}
+	r.sent[gifter.Name] = body
+	return "id", r.err
+}
+
+func TestDeliver(t *testing.T) {
+	ctx := context.Background()
+	assignment, err := Draw(ctx, flintstones, Constraints{}, DrawOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Deliver(ctx, assignment, flintstones); err == nil {
+		t.Errorf("Deliver() without a notifier should return an error")
+	}
+	if err := Deliver(ctx, send.Assignment{}, flintstones, WithNotifier(&recorder{})); err == nil {
+		t.Errorf("Deliver() with an empty assignment should return an error")
+	}
+
+	email := &recorder{}
+	sms := &recorder{}
+	participants := slices.Clone(flintstones)
+	participants[0].Channels = []string{"sms"}
+	var out strings.Builder
+	if err := Deliver(ctx, assignment, participants, WithNotifier(email), WithChannel("sms", sms), WithConcurrency(2), WithOutput(&out)); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if len(email.sent) != 3 || len(sms.sent) != 1 || sms.sent["Fred"] == "" {
+		t.Errorf("Deliver() sent %d emails and %d texts, want 3 and 1", len(email.sent), len(sms.sent))
+	}
+
+	if out.String() != "4 assignments sent\n" {
+		t.Errorf("Deliver() wrote %q", out.String())
+	}
+
+	failing := &recorder{err: errors.New("down")}
+	var report *send.DeliveryError
+	out.Reset()
+	if err := Deliver(ctx, assignment, flintstones, WithNotifier(failing), WithOutput(&out)); !errors.As(err, &report) || len(report.Failed) != 4 {
+		t.Errorf("Deliver() with a failing notifier error = %v, want a DeliveryError for everyone", err)
+	}
+	if !strings.HasPrefix(out.String(), "0 assignments sent\nnot every assignment was sent: ") || !strings.Contains(out.String(), "down") {
+		t.Errorf("Deliver() with a failing notifier wrote %q", out.String())
+	}
+}
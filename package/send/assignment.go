@@ -0,0 +1,80 @@
+package send
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Assignment is who buys for whom, by participant name. Pairs are in
+// gifter order and giftees sorted, so the same draw always encodes the same
+// way and two assignments can be compared.
+type Assignment struct {
+	// Draw is the id of the saved draw the assignment is from, if any.
+	Draw           string `json:"draw,omitempty"`
+	GiftsPerPerson int    `json:"gifts_per_person"`
+	Pairs          []Pair `json:"pairs"`
+}
+
+// Pair is a gifter and the people they buy for.
+type Pair struct {
+	Gifter  string   `json:"gifter"`
+	Giftees []string `json:"giftees"`
+}
+
+func newAssignment(pairs map[string][]string, n int) Assignment {
+	a := Assignment{GiftsPerPerson: max(n, 1), Pairs: make([]Pair, 0, len(pairs))}
+	for _, gifter := range slices.Sorted(maps.Keys(pairs)) {
+		giftees := slices.Clone(pairs[gifter])
+		slices.Sort(giftees)
+		a.Pairs = append(a.Pairs, Pair{Gifter: gifter, Giftees: giftees})
+	}
+	return a
+}
+
+// Giftees returns who gifter buys for, and whether they are in the
+// assignment. An assignment decoded from JSON may not be in order, so the
+// pairs are searched one by one.
+func (a Assignment) Giftees(gifter string) ([]string, bool) {
+	i := slices.IndexFunc(a.Pairs, func(p Pair) bool { return p.Gifter == gifter })
+	if i < 0 {
+		return nil, false
+	}
+	return a.Pairs[i].Giftees, true
+}
+
+// pairs returns a copy of the assignment keyed by gifter, for changing it.
+func (a Assignment) pairs() map[string][]string {
+	pairs := make(map[string][]string, len(a.Pairs))
+	for _, pair := range a.Pairs {
+		pairs[pair.Gifter] = slices.Clone(pair.Giftees)
+	}
+	return pairs
+}
+
+// check makes sure everyone in the assignment is still in p, which may have
+// new email addresses or wishlists since names were drawn.
+func (a Assignment) check(p Participants) error {
+	var missing []string
+	for _, pair := range a.Pairs {
+		for _, name := range append([]string{pair.Gifter}, pair.Giftees...) {
+			if _, ok := p[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("%s are in the draw but not the participants file", joinList(slices.Compact(missing)))
+	}
+	return nil
+}
+
+// lookup returns the participants in a pair.
+func (p Pair) lookup(participants Participants) (Participant, []Participant) {
+	giftees := make([]Participant, 0, len(p.Giftees))
+	for _, name := range p.Giftees {
+		giftees = append(giftees, participants[name])
+	}
+	return participants[p.Gifter], giftees
+}
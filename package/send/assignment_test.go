@@ -0,0 +1,59 @@
+package send
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestAssignment(t *testing.T) {
+	a := newAssignment(map[string][]string{
+		"Wilma":  {"Fred"},
+		"Barney": {"Wilma"},
+		"Fred":   {"Betty"},
+		"Betty":  {"Barney"},
+	}, 1)
+	gifters := make([]string, 0, len(a.Pairs))
+	for _, pair := range a.Pairs {
+		gifters = append(gifters, pair.Gifter)
+	}
+	if !slices.Equal(gifters, []string{"Barney", "Betty", "Fred", "Wilma"}) {
+		t.Errorf("newAssignment() gifters = %v, want them sorted", gifters)
+	}
+	if giftees, ok := a.Giftees("Fred"); !ok || !slices.Equal(giftees, []string{"Betty"}) {
+		t.Errorf("Assignment.Giftees(Fred) = %v, %v", giftees, ok)
+	}
+	if _, ok := a.Giftees("Dino"); ok {
+		t.Errorf("Assignment.Giftees(Dino) found someone who isn't in the assignment")
+	}
+
+	// Decoded from JSON the pairs can be in any order
+	unordered := Assignment{Pairs: []Pair{{Gifter: "Wilma", Giftees: []string{"Fred"}}, {Gifter: "Barney", Giftees: []string{"Wilma"}}}}
+	if giftees, ok := unordered.Giftees("Barney"); !ok || !slices.Equal(giftees, []string{"Wilma"}) {
+		t.Errorf("Assignment.Giftees(Barney) out of order = %v, %v", giftees, ok)
+	}
+
+	d := newDraw(a)
+	got := d.Assignment
+	if got.Draw != d.ID {
+		t.Errorf("Draw.Assignment.Draw = %q, want %q", got.Draw, d.ID)
+	}
+	got.Draw = ""
+	if !reflect.DeepEqual(got, a) {
+		t.Errorf("Draw.Assignment = %+v, want %+v", got, a)
+	}
+
+	first, err := json.Marshal(d.Assignment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := json.Marshal(d.Assignment)
+	if string(first) != string(second) {
+		t.Errorf("the same draw encoded as %s and %s", first, second)
+	}
+	var decoded Assignment
+	if err := json.Unmarshal(first, &decoded); err != nil || !reflect.DeepEqual(decoded, d.Assignment) {
+		t.Errorf("decoded %s as %+v, %v", first, decoded, err)
+	}
+}
@@ -0,0 +1,221 @@
+package send
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PairingError explains why no draw is possible. Gifters is a smallest set
+// of people who between them can't find enough giftees, Giftees is
+// everyone they are still allowed to buy for and Exclusions are the rules
+// that keep them from anyone else.
+type PairingError struct {
+	Gifters        []string
+	Giftees        []string
+	GiftsPerPerson int
+	Exclusions     []Exclusion
+	// Suggestions are single changes that would make a draw possible.
+	Suggestions []string
+}
+
+// Exclusion is a hard rule stopping Gifter from buying for Giftee.
+type Exclusion struct {
+	Gifter string
+	Giftee string
+	Reason string
+}
+
+func (e *PairingError) Error() string {
+	var b strings.Builder
+	switch {
+	case len(e.Giftees) == 0 && len(e.Gifters) > 1 && e.closed():
+		fmt.Fprintf(&b, "%s can only give to each other", joinList(e.Gifters))
+	case len(e.Giftees) == 0:
+		fmt.Fprintf(&b, "%s can't give to anyone", joinList(e.Gifters))
+	case slices.Equal(e.Gifters, e.Giftees):
+		fmt.Fprintf(&b, "%s can only give to each other", joinList(e.Gifters))
+	default:
+		fmt.Fprintf(&b, "%s can only give to %s", joinList(e.Gifters), joinList(e.Giftees))
+	}
+	if e.GiftsPerPerson > 1 {
+		fmt.Fprintf(&b, ", which isn't enough for %d gifts each", e.GiftsPerPerson)
+	}
+	if len(e.Exclusions) > 0 {
+		b.WriteString("\nbecause:")
+		for _, x := range e.Exclusions {
+			fmt.Fprintf(&b, "\n  - %s can't give to %s: %s", x.Gifter, x.Giftee, x.Reason)
+		}
+	}
+	if len(e.Suggestions) > 0 {
+		b.WriteString("\nto fix this, try one of:")
+		for _, s := range e.Suggestions {
+			fmt.Fprintf(&b, "\n  - %s", s)
+		}
+	}
+	return b.String()
+}
+
+// closed reports whether the gifters are only kept from each other, so with
+// nobody else to buy for they can only give to each other.
+func (e *PairingError) closed() bool {
+	for _, x := range e.Exclusions {
+		if !slices.Contains(e.Gifters, x.Giftee) {
+			return false
+		}
+	}
+	return true
+}
+
+// diagnose explains why p can't be drawn with n gifts each. It returns nil
+// if a draw is in fact possible.
+//
+// When the max flow used by optimisePairs falls short, the gifters still
+// reachable from the source form a set that can't be satisfied: together
+// they need more gifts than the people they may buy for can take. That set
+// is shrunk until removing anyone would resolve the conflict. Partners are
+// removed together, as the rule between them goes both ways.
+func diagnose(p Participants, n int) error {
+	if n < 1 {
+		n = 1
+	}
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	allowed := func(gifter, giftee string) bool { return canGive(p[gifter], p[giftee]) }
+	g, ok := drawFlow(names, n, allowed)
+	if ok {
+		return nil
+	}
+
+	reached := g.reachable(2 * len(names))
+	var gifters []string
+	for i, name := range names {
+		if reached[i] {
+			gifters = append(gifters, name)
+		}
+	}
+	for i := 0; i < len(gifters); {
+		removed := gifters[i]
+		smaller := slices.DeleteFunc(slices.Clone(gifters), func(name string) bool {
+			return name == removed || name == p[removed].Partner
+		})
+		if len(smaller) > 0 && deficient(smaller, names, n, allowed) {
+			gifters = smaller
+			continue
+		}
+		i++
+	}
+
+	e := &PairingError{Gifters: gifters, GiftsPerPerson: n}
+	giftees := make(map[string]struct{})
+	for _, gifter := range gifters {
+		for _, giftee := range names {
+			if allowed(gifter, giftee) {
+				giftees[giftee] = struct{}{}
+			} else if gifter != giftee {
+				e.Exclusions = append(e.Exclusions, Exclusion{
+					Gifter: gifter,
+					Giftee: giftee,
+					Reason: exclusion(p[gifter], p[giftee]),
+				})
+			}
+		}
+	}
+	for _, name := range names {
+		if _, ok := giftees[name]; ok {
+			e.Giftees = append(e.Giftees, name)
+		}
+	}
+
+	suggested := make(map[Exclusion]bool)
+	for _, x := range e.Exclusions {
+		if suggested[x] {
+			continue
+		}
+		// A partner rule is lifted both ways, as partners are listed
+		// against each other.
+		lifted := []Exclusion{x}
+		if p[x.Gifter].Partner == x.Giftee {
+			lifted = append(lifted, Exclusion{Gifter: x.Giftee, Giftee: x.Gifter, Reason: exclusion(p[x.Giftee], p[x.Gifter])})
+		}
+		relaxed := func(gifter, giftee string) bool {
+			for _, l := range lifted {
+				if gifter == l.Gifter && giftee == l.Giftee {
+					return true
+				}
+			}
+			return allowed(gifter, giftee)
+		}
+		for _, l := range lifted {
+			suggested[l] = true
+		}
+		if _, ok := drawFlow(names, n, relaxed); !ok {
+			continue
+		}
+		if len(lifted) > 1 {
+			e.Suggestions = append(e.Suggestions, fmt.Sprintf("remove the partner exclusion between %s", joinList(sortedNames(x.Gifter, x.Giftee))))
+		} else {
+			e.Suggestions = append(e.Suggestions, fmt.Sprintf("allow %s to give to %s", x.Gifter, x.Giftee))
+		}
+	}
+	if n > 1 {
+		if _, ok := drawFlow(names, n-1, allowed); ok {
+			e.Suggestions = append(e.Suggestions, fmt.Sprintf("give %d gifts each instead of %d", n-1, n))
+		}
+	}
+	e.Suggestions = append(e.Suggestions, "add another participant who can give to and receive from "+joinList(gifters))
+	return e
+}
+
+// drawFlow runs a max flow over the draw and reports whether everyone can
+// give and receive n gifts. Gifters are nodes 0 to len(names)-1, giftees
+// follow them and the source and sink come last.
+func drawFlow(names []string, n int, allowed func(gifter, giftee string) bool) (*flowGraph, bool) {
+	size := len(names)
+	source, sink := 2*size, 2*size+1
+	g := newFlowGraph(2*size + 2)
+	for i, gifter := range names {
+		g.addEdge(source, i, n, 0)
+		g.addEdge(size+i, sink, n, 0)
+		for j, giftee := range names {
+			if allowed(gifter, giftee) {
+				g.addEdge(i, size+j, 1, 0)
+			}
+		}
+	}
+	return g, g.minCostFlow(source, sink, size*n) == size*n
+}
+
+// deficient reports whether gifters need more gifts than the people they
+// may buy for can take, counting at most one gift from each gifter to each
+// giftee and at most n gifts for each giftee.
+func deficient(gifters, names []string, n int, allowed func(gifter, giftee string) bool) bool {
+	capacity := 0
+	for _, giftee := range names {
+		from := 0
+		for _, gifter := range gifters {
+			if allowed(gifter, giftee) {
+				from++
+			}
+		}
+		capacity += min(from, n)
+	}
+	return capacity < len(gifters)*n
+}
+
+func sortedNames(names ...string) []string {
+	slices.Sort(names)
+	return names
+}
+
+// joinList lists names in a sentence, e.g. "Fred, Wilma and Betty".
+func joinList(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
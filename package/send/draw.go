@@ -0,0 +1,78 @@
+package send
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/filestore"
+)
+
+// Draw is a saved assignment, so it can be sent again, resumed or repaired
+// without drawing names again.
+type Draw struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	// Assignment is who buys for whom. Its Draw is always ID.
+	Assignment Assignment `json:"assignment"`
+	// Revisions counts how many times each gifter's assignment has been
+	// changed or sent again since the draw, so a new delivery isn't taken
+	// for a repeat of the last one.
+	Revisions map[string]int `json:"revisions,omitempty"`
+}
+
+// Revise records that gifter is to be sent their assignment again.
+func (d *Draw) Revise(gifter string) {
+	if d.Revisions == nil {
+		d.Revisions = map[string]int{}
+	}
+	d.Revisions[gifter]++
+}
+
+func newDraw(a Assignment) *Draw {
+	id := make([]byte, 8)
+	rand.Read(id)
+	a = newAssignment(a.pairs(), a.GiftsPerPerson)
+	a.Draw = hex.EncodeToString(id)
+	return &Draw{ID: a.Draw, Created: time.Now().UTC().Truncate(time.Second), Assignment: a}
+}
+
+// LoadDraw reads a draw saved with Save.
+func LoadDraw(path string) (*Draw, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading draw: %v", err)
+	}
+	var d Draw
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("error reading draw %s: %v", path, err)
+	}
+	if d.ID == "" || len(d.Assignment.Pairs) == 0 {
+		return nil, fmt.Errorf("%s is not a saved draw", path)
+	}
+	// Put back in order, in case the file was edited by hand
+	pairs := d.Assignment.pairs()
+	if len(pairs) != len(d.Assignment.Pairs) {
+		return nil, fmt.Errorf("%s lists a gifter more than once", path)
+	}
+	d.Assignment = newAssignment(pairs, d.Assignment.GiftsPerPerson)
+	d.Assignment.Draw = d.ID
+	return &d, nil
+}
+
+// Save writes the draw to path so a crash never leaves half a draw
+// behind. The file holds everyone's assignment, so only its owner can read
+// it.
+func (d *Draw) Save(path string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding draw: %v", err)
+	}
+	if err := filestore.WriteFile(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("error saving draw: %v", err)
+	}
+	return nil
+}
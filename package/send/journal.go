@@ -0,0 +1,218 @@
+package send
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delivery is a journal entry: a gifter was sent their assignment from a
+// draw.
+type Delivery struct {
+	Draw   string `json:"draw"`
+	Gifter string `json:"gifter"`
+	// Channel is how the assignment was delivered, empty for the default
+	// notifier.
+	Channel string `json:"channel,omitempty"`
+	// MessageID is the provider's id for the message, when it gives one.
+	MessageID string `json:"message_id,omitempty"`
+	// Revision is the gifter's revision in the draw when it was sent.
+	Revision int `json:"revision,omitempty"`
+	// Pending is set on the entry written just before a message is handed
+	// to the provider. One that is never followed by a delivery of the same
+	// revision may or may not have reached the gifter.
+	Pending bool      `json:"pending,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Journal records every delivery in an append only file of JSON lines, so
+// a run that dies part way can be resumed without sending anyone their
+// assignment twice.
+type Journal struct {
+	path      string
+	mu        sync.Mutex
+	delivered map[string]map[string]Delivery
+	pending   map[string]map[string]Delivery
+	now       func() time.Time
+}
+
+// OpenJournal reads the journal at path. A missing file is an empty
+// journal. A partly written last line, left by a crash, is cut off.
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{path: path, delivered: map[string]map[string]Delivery{}, pending: map[string]map[string]Delivery{}, now: time.Now}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %v", err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	good := 0
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			good += len(line)
+			continue
+		}
+		var d Delivery
+		if err := json.Unmarshal(line, &d); err != nil || !bytes.HasSuffix(line, []byte("\n")) {
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("error reading journal line %d: %v", i+1, err)
+		}
+		j.add(d)
+		good += len(line)
+	}
+	if good < len(data) {
+		if err := os.Truncate(path, int64(good)); err != nil {
+			return nil, fmt.Errorf("error repairing journal: %v", err)
+		}
+	}
+	return j, nil
+}
+
+func (j *Journal) add(d Delivery) {
+	if d.Pending {
+		if j.pending[d.Draw] == nil {
+			j.pending[d.Draw] = map[string]Delivery{}
+		}
+		j.pending[d.Draw][d.Gifter] = d
+		return
+	}
+	if j.delivered[d.Draw] == nil {
+		j.delivered[d.Draw] = map[string]Delivery{}
+	}
+	j.delivered[d.Draw][d.Gifter] = d
+	if p, ok := j.pending[d.Draw][d.Gifter]; ok && p.Revision <= d.Revision {
+		delete(j.pending[d.Draw], d.Gifter)
+	}
+}
+
+// Delivered reports whether gifter has been sent their assignment from the
+// draw.
+func (j *Journal) Delivered(draw, gifter string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.delivered[draw][gifter]
+	return ok
+}
+
+// Delivery returns the last time gifter was sent their assignment from
+// the draw.
+func (j *Journal) Delivery(draw, gifter string) (Delivery, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	d, ok := j.delivered[draw][gifter]
+	return d, ok
+}
+
+// Pending returns the delivery to gifter from the draw that was started
+// but never recorded as made, if there is one.
+func (j *Journal) Pending(draw, gifter string) (Delivery, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	d, ok := j.pending[draw][gifter]
+	return d, ok
+}
+
+// Deliveries lists everyone sent their assignment from the draw.
+func (j *Journal) Deliveries(draw string) []Delivery {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	deliveries := make([]Delivery, 0, len(j.delivered[draw]))
+	for _, d := range j.delivered[draw] {
+		deliveries = append(deliveries, d)
+	}
+	return deliveries
+}
+
+// Started reports whether anything from the draw has been sent, or was on
+// its way when a run stopped.
+func (j *Journal) Started(draw string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.delivered[draw]) > 0 || len(j.pending[draw]) > 0
+}
+
+// Record appends a delivery, or a pending one, and syncs it to disk before
+// returning.
+func (j *Journal) Record(d Delivery) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if d.Time.IsZero() {
+		d.Time = j.now().UTC()
+	}
+	line, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("error encoding journal entry: %v", err)
+	}
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening journal: %v", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	j.add(d)
+	return nil
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context carrying the key for one delivery.
+// The key is the same every time the same assignment is sent on the same
+// channel, so providers that support it can drop repeats.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKey returns the key set by WithIdempotencyKey, or "".
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// deliveryKey derives a short, stable idempotency key. Each revision of an
+// assignment gets its own key.
+func deliveryKey(draw, gifter, channel string, revision int) string {
+	in := draw + "\x00" + gifter + "\x00" + channel
+	if revision > 0 {
+		in += "\x00" + strconv.Itoa(revision)
+	}
+	sum := sha256.Sum256([]byte(in))
+	return hex.EncodeToString(sum[:12])
+}
+
+// MessageID returns a Message-ID header derived from the idempotency key in
+// ctx, or "" if there isn't one. Every copy of the same assignment then
+// carries the same Message-ID, so a repeat can be recognised; mail
+// providers still deliver it.
+func MessageID(ctx context.Context, senderEmail string) string {
+	key := IdempotencyKey(ctx)
+	if key == "" {
+		return ""
+	}
+	domain := "localhost"
+	if i := strings.LastIndex(senderEmail, "@"); i >= 0 && i < len(senderEmail)-1 {
+		domain = senderEmail[i+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", key, domain)
+}
@@ -0,0 +1,229 @@
+package send
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestOpenJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+	for _, gifter := range []string{"Fred", "Wilma"} {
+		if err := j.Record(Delivery{Draw: "a", Gifter: gifter, MessageID: gifter + "-id"}); err != nil {
+			t.Fatalf("Journal.Record() error = %v", err)
+		}
+	}
+
+	// A crash while writing leaves half a line behind.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"draw":"a","gifter":"Barn`)
+	file.Close()
+
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+	if !j.Delivered("a", "Fred") || !j.Delivered("a", "Wilma") {
+		t.Errorf("journal lost a delivery")
+	}
+	if j.Delivered("a", "Barney") || j.Delivered("b", "Fred") {
+		t.Errorf("journal has a delivery that wasn't recorded")
+	}
+	if err := j.Record(Delivery{Draw: "a", Gifter: "Barney"}); err != nil {
+		t.Fatalf("Journal.Record() error = %v", err)
+	}
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal() after repair error = %v", err)
+	}
+	if got := len(j.Deliveries("a")); got != 3 {
+		t.Errorf("journal has %d deliveries, want 3", got)
+	}
+
+	// A pending delivery lasts until one of the same revision is made.
+	for _, d := range []Delivery{
+		{Draw: "a", Gifter: "Pebbles", Pending: true},
+		{Draw: "a", Gifter: "Fred", Revision: 1, Pending: true},
+		{Draw: "a", Gifter: "Barney", Revision: 1, Pending: true},
+		{Draw: "a", Gifter: "Barney", Revision: 1},
+	} {
+		if err := j.Record(d); err != nil {
+			t.Fatalf("Journal.Record() error = %v", err)
+		}
+	}
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+	for gifter, want := range map[string]bool{"Pebbles": true, "Fred": true, "Barney": false, "Wilma": false} {
+		if _, got := j.Pending("a", gifter); got != want {
+			t.Errorf("Journal.Pending(%s) = %v, want %v", gifter, got, want)
+		}
+	}
+	if j.Delivered("a", "Pebbles") || !j.Started("a") || j.Started("b") {
+		t.Errorf("a pending delivery was counted as made, or the draw as not started")
+	}
+}
+
+func TestDraw_SaveLoad(t *testing.T) {
+	s := &Sender{ParticipantLoader: testParticipantsLoaderMany(6), GiftsPerPerson: 1}
+	d, err := s.NewDraw(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Sender.NewDraw() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "draw.json")
+	if err := d.Save(path); err != nil {
+		t.Fatalf("Draw.Save() error = %v", err)
+	}
+	loaded, err := LoadDraw(path)
+	if err != nil {
+		t.Fatalf("LoadDraw() error = %v", err)
+	}
+	if loaded.ID != d.ID || len(loaded.Assignment.Pairs) != 6 || !reflect.DeepEqual(loaded.Assignment, d.Assignment) {
+		t.Errorf("LoadDraw() = %+v, want %+v", loaded, d)
+	}
+
+	// A file edited by hand is put back in order, and a gifter listed
+	// twice is refused
+	edited := filepath.Join(t.TempDir(), "edited.json")
+	os.WriteFile(edited, []byte(`{"id":"a","assignment":{"gifts_per_person":1,"pairs":[{"gifter":"Wilma","giftees":["Fred"]},{"gifter":"Fred","giftees":["Wilma"]}]}}`), 0o600)
+	if loaded, err := LoadDraw(edited); err != nil || loaded.Assignment.Pairs[0].Gifter != "Fred" || loaded.Assignment.Draw != "a" {
+		t.Errorf("LoadDraw() of an edited file = %+v, %v", loaded, err)
+	}
+	os.WriteFile(edited, []byte(`{"id":"a","assignment":{"gifts_per_person":1,"pairs":[{"gifter":"Fred","giftees":["Wilma"]},{"gifter":"Fred","giftees":["Barney"]}]}}`), 0o600)
+	if _, err := LoadDraw(edited); err == nil {
+		t.Errorf("LoadDraw() with a gifter listed twice should return an error")
+	}
+
+	participants, _ := testParticipantsLoaderMany(5).LoadParticipants(context.Background(), "")
+	if err := d.Assignment.check(participants); err == nil {
+		t.Errorf("Assignment.check() with a participant missing should return an error")
+	}
+}
+
+func TestSender_SendResume(t *testing.T) {
+	ctx := context.Background()
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var keys []string
+	var sent []string
+	s := &Sender{
+		ParticipantLoader: testParticipantsLoaderMany(5),
+		EmailTemplate:     &Email{},
+		Journal:           j,
+	}
+	s.Notifier = testNotifierKeys(func(gifter string, key string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, gifter)
+		keys = append(keys, key)
+		return nil
+	})
+	if err := s.Send(ctx, ""); err == nil {
+		t.Errorf("Sender.Send() with a journal but no draw should return an error")
+	}
+
+	s.Draw, err = s.NewDraw(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gifter := range []string{"0", "3"} {
+		if err := j.Record(Delivery{Draw: s.Draw.ID, Gifter: gifter}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Send(ctx, ""); err != nil {
+		t.Fatalf("Sender.Send() error = %v", err)
+	}
+	slices.Sort(sent)
+	if want := []string{"1", "2", "4"}; !slices.Equal(sent, want) {
+		t.Errorf("resumed send notified %v, want %v", sent, want)
+	}
+	if got := len(j.Deliveries(s.Draw.ID)); got != 5 {
+		t.Errorf("journal has %d deliveries, want 5", got)
+	}
+	for _, key := range keys {
+		if key == "" {
+			t.Errorf("a delivery had no idempotency key")
+		}
+	}
+
+	// Everyone has been sent their assignment, so sending again sends
+	// nothing.
+	sent = nil
+	if err := s.Send(ctx, ""); err != nil {
+		t.Fatalf("Sender.Send() error = %v", err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("sending a finished draw notified %v", sent)
+	}
+
+	// A revised assignment is sent again with a new key, by Resend or by
+	// resuming
+	keys = nil
+	s.Draw.Revise("1")
+	if err := s.Resend(ctx, "", "1"); err != nil {
+		t.Fatalf("Sender.Resend() error = %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "1" || keys[0] == deliveryKey(s.Draw.ID, "1", "", 0) {
+		t.Errorf("Sender.Resend() notified %v with keys %v", sent, keys)
+	}
+	s.Draw.Revise("2")
+	sent = nil
+	if err := s.Send(ctx, ""); err != nil {
+		t.Fatalf("Sender.Send() error = %v", err)
+	}
+	if !slices.Equal(sent, []string{"2"}) {
+		t.Errorf("sending after a revision notified %v, want [2]", sent)
+	}
+
+	// A run stopped while sending to 4 leaves them pending, so resuming
+	// reports them instead of sending again unless told to
+	s.Draw.Revise("4")
+	if err := j.Record(Delivery{Draw: s.Draw.ID, Gifter: "4", Revision: s.Draw.Revisions["4"], Pending: true}); err != nil {
+		t.Fatal(err)
+	}
+	sent = nil
+	err = s.Send(ctx, "")
+	var report *DeliveryError
+	if !errors.As(err, &report) || !slices.Equal(report.MaybeSent, []string{"4"}) {
+		t.Fatalf("Sender.Send() error = %v, want 4 maybe sent", err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("resuming after a pending delivery notified %v", sent)
+	}
+	s.ResendPending = true
+	if err := s.Send(ctx, ""); err != nil {
+		t.Fatalf("Sender.Send() with ResendPending error = %v", err)
+	}
+	if !slices.Equal(sent, []string{"4"}) {
+		t.Errorf("Sender.Send() with ResendPending notified %v, want [4]", sent)
+	}
+	if _, ok := j.Pending(s.Draw.ID, "4"); ok {
+		t.Errorf("the delivery to 4 is still pending after it was made")
+	}
+	if err := s.Resend(ctx, "", "nobody"); err == nil {
+		t.Errorf("Sender.Resend() for someone not in the draw should return an error")
+	}
+}
+
+type testNotifierKeys func(gifter, key string) error
+
+func (f testNotifierKeys) Notify(ctx context.Context, gifter Participant, giftees []Participant, emailTemplate *Email) (string, error) {
+	return gifter.Name + "-id", f(gifter.Name, IdempotencyKey(ctx))
+}
@@ -0,0 +1,16 @@
+package send
+
+import "strings"
+
+// SplitList splits s at any of the separators, trimming space and dropping
+// empty items. Participant files and forms use it for every list field, e.g.
+// SplitList("Bowling, Golf", ",") or SplitList("Barney; Betty", ";").
+func SplitList(s, separators string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return strings.ContainsRune(separators, r) }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,28 @@
+package send
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name       string
+		s          string
+		separators string
+		want       []string
+	}{
+		{name: "Empty", s: "", separators: ",", want: nil},
+		{name: "Only separators and space", s: " , ,", separators: ",", want: nil},
+		{name: "Commas", s: " Bowling,Golf , ,Jokes,", separators: ",", want: []string{"Bowling", "Golf", "Jokes"}},
+		{name: "Semicolons keep commas", s: "Barney; Betty, Jr", separators: ";", want: []string{"Barney", "Betty, Jr"}},
+		{name: "Either separator", s: "sms; email,slack", separators: ";,", want: []string{"sms", "email", "slack"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitList(tt.s, tt.separators); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitList(%q, %q) = %q, want %q", tt.s, tt.separators, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package send
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// tieBreak is the range of the random cost added to every pairing so that
+// draws with equal scores are chosen at random.
+const tieBreak = 1000
+
+// optimisePairs gives every participant n giftees, like pairParticipants,
+// but picks the draw with the highest total preference score.
+//
+// The draw is solved as a min cost flow: the source gives n units to every
+// gifter, each allowed gifter/giftee pair carries one unit and every giftee
+// passes n units to the sink. Scores become costs so the cheapest full flow
+// is the best scoring draw, and a small random cost on every pair breaks
+// ties without ever outweighing a single point of score.
+func optimisePairs(p Participants, n int, prefs Preferences) (Assignment, error) {
+	if n < 1 {
+		n = 1
+	}
+	if len(p) == 0 {
+		return newAssignment(nil, n), nil
+	}
+	if n >= len(p) {
+		return Assignment{}, fmt.Errorf("can't give %d gifts each with only %d participants", n, len(p))
+	}
+
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	shuffle(names)
+
+	best := math.MinInt
+	for _, gifter := range names {
+		for _, giftee := range names {
+			if canGive(p[gifter], p[giftee]) {
+				best = max(best, prefs.Score(p[gifter], p[giftee]))
+			}
+		}
+	}
+
+	size := len(names)
+	source, sink := 2*size, 2*size+1
+	g := newFlowGraph(2*size + 2)
+	scale := int64(size*n) * tieBreak
+	type pairEdge struct{ gifter, giftee, edge int }
+	var pairEdges []pairEdge
+	for i, gifter := range names {
+		g.addEdge(source, i, n, 0)
+		g.addEdge(size+i, sink, n, 0)
+		for j, giftee := range names {
+			if !canGive(p[gifter], p[giftee]) {
+				continue
+			}
+			cost := int64(best-prefs.Score(p[gifter], p[giftee]))*scale + rand.Int64N(tieBreak)
+			pairEdges = append(pairEdges, pairEdge{gifter: i, giftee: j, edge: len(g.adj[i])})
+			g.addEdge(i, size+j, 1, cost)
+		}
+	}
+
+	if flow := g.minCostFlow(source, sink, size*n); flow < size*n {
+		return Assignment{}, diagnose(p, n)
+	}
+
+	pairs := make(map[string][]string, len(p))
+	for _, e := range pairEdges {
+		if g.adj[e.gifter][e.edge].cap == 0 {
+			gifter := names[e.gifter]
+			pairs[gifter] = append(pairs[gifter], names[e.giftee])
+		}
+	}
+	return newAssignment(pairs, n), nil
+}
+
+type flowEdge struct {
+	to, rev, cap int
+	cost         int64
+}
+
+type flowGraph struct {
+	adj [][]flowEdge
+}
+
+func newFlowGraph(nodes int) *flowGraph {
+	return &flowGraph{adj: make([][]flowEdge, nodes)}
+}
+
+// reachable marks every node that can still be reached from s through
+// edges with spare capacity.
+func (g *flowGraph) reachable(s int) []bool {
+	seen := make([]bool, len(g.adj))
+	seen[s] = true
+	queue := []int{s}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for _, e := range g.adj[u] {
+			if e.cap > 0 && !seen[e.to] {
+				seen[e.to] = true
+				queue = append(queue, e.to)
+			}
+		}
+	}
+	return seen
+}
+
+func (g *flowGraph) addEdge(from, to, capacity int, cost int64) {
+	g.adj[from] = append(g.adj[from], flowEdge{to: to, rev: len(g.adj[to]), cap: capacity, cost: cost})
+	g.adj[to] = append(g.adj[to], flowEdge{to: from, rev: len(g.adj[from]) - 1, cap: 0, cost: -cost})
+}
+
+// minCostFlow pushes up to want units from s to t along successive
+// cheapest paths and returns how many units it managed to push. Every cost
+// starts non-negative, so Dijkstra with node potentials finds each path.
+func (g *flowGraph) minCostFlow(s, t, want int) int {
+	nodes := len(g.adj)
+	potential := make([]int64, nodes)
+	dist := make([]int64, nodes)
+	prevNode := make([]int, nodes)
+	prevEdge := make([]int, nodes)
+	done := make([]bool, nodes)
+	flow := 0
+	for flow < want {
+		for i := range dist {
+			dist[i] = math.MaxInt64
+			done[i] = false
+		}
+		dist[s] = 0
+		// The graph is dense, so a simple O(V^2) Dijkstra beats a heap.
+		for {
+			u := -1
+			for v := 0; v < nodes; v++ {
+				if !done[v] && dist[v] != math.MaxInt64 && (u == -1 || dist[v] < dist[u]) {
+					u = v
+				}
+			}
+			if u == -1 {
+				break
+			}
+			done[u] = true
+			for i, e := range g.adj[u] {
+				if e.cap == 0 {
+					continue
+				}
+				d := dist[u] + e.cost + potential[u] - potential[e.to]
+				if d < dist[e.to] {
+					dist[e.to] = d
+					prevNode[e.to] = u
+					prevEdge[e.to] = i
+				}
+			}
+		}
+		if dist[t] == math.MaxInt64 {
+			return flow
+		}
+		for v := range potential {
+			if dist[v] != math.MaxInt64 {
+				potential[v] += dist[v]
+			}
+		}
+		push := want - flow
+		for v := t; v != s; v = prevNode[v] {
+			push = min(push, g.adj[prevNode[v]][prevEdge[v]].cap)
+		}
+		for v := t; v != s; v = prevNode[v] {
+			e := &g.adj[prevNode[v]][prevEdge[v]]
+			e.cap -= push
+			g.adj[v][e.rev].cap += push
+		}
+		flow += push
+	}
+	return flow
+}
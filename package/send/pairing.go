@@ -0,0 +1,184 @@
+package send
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+)
+
+// maxPairingSteps bounds the search so an unlucky draw over a large group
+// fails instead of hanging.
+const maxPairingSteps = 1_000_000
+
+// pairParticipants gives every participant n giftees so that everyone also
+// receives exactly n gifts. Nobody buys for themselves, their partner or
+// anyone they excluded, and nobody buys for the same person twice.
+func pairParticipants(p Participants, n int) (Assignment, error) {
+	if n < 1 {
+		n = 1
+	}
+	if len(p) == 0 {
+		return newAssignment(nil, n), nil
+	}
+	if n >= len(p) {
+		return Assignment{}, fmt.Errorf("can't give %d gifts each with only %d participants", n, len(p))
+	}
+
+	s := newPairingSolver(p, n)
+	if !s.assign(0) {
+		if err := diagnose(p, n); err != nil {
+			return Assignment{}, err
+		}
+		return Assignment{}, fmt.Errorf("gave up after %d attempts", maxPairingSteps)
+	}
+
+	pairs := make(map[string][]string, len(p))
+	for _, gifter := range s.order {
+		pairs[gifter] = s.giftees(gifter)
+	}
+	return newAssignment(pairs, n), nil
+}
+
+// pairingSolver is a randomised backtracking search. Gifters and each
+// gifter's candidate giftees are shuffled once, so every run explores the
+// possibilities in a different order. A gifter takes candidates in list
+// order so the same set of giftees is never tried twice.
+type pairingSolver struct {
+	n          int
+	order      []string
+	candidates map[string][]string
+	received   map[string]int
+	chosen     map[string][]int
+	steps      int
+}
+
+func newPairingSolver(p Participants, n int) *pairingSolver {
+	order := make([]string, 0, len(p))
+	for name := range p {
+		order = append(order, name)
+	}
+	slices.Sort(order)
+	shuffle(order)
+	candidates := make(map[string][]string, len(p))
+	for _, gifter := range order {
+		for _, giftee := range order {
+			if canGive(p[gifter], p[giftee]) {
+				candidates[gifter] = append(candidates[gifter], giftee)
+			}
+		}
+		shuffle(candidates[gifter])
+	}
+	return &pairingSolver{
+		n:          n,
+		order:      order,
+		candidates: candidates,
+		received:   make(map[string]int, len(p)),
+		chosen:     make(map[string][]int, len(p)),
+	}
+}
+
+// giftees returns the names chosen for gifter so far.
+func (s *pairingSolver) giftees(gifter string) []string {
+	names := make([]string, 0, len(s.chosen[gifter]))
+	for _, i := range s.chosen[gifter] {
+		names = append(names, s.candidates[gifter][i])
+	}
+	return names
+}
+
+// assign fills the giftee slot with the given index and everything after
+// it, undoing its choices if no complete assignment can be found.
+func (s *pairingSolver) assign(slot int) bool {
+	if slot == len(s.order)*s.n {
+		return true
+	}
+	s.steps++
+	if s.steps > maxPairingSteps {
+		return false
+	}
+	gifter := s.order[slot/s.n]
+	start := 0
+	if chosen := s.chosen[gifter]; len(chosen) > 0 {
+		start = chosen[len(chosen)-1] + 1
+	}
+	for i := start; i < len(s.candidates[gifter]); i++ {
+		giftee := s.candidates[gifter][i]
+		if s.received[giftee] == s.n {
+			continue
+		}
+		s.chosen[gifter] = append(s.chosen[gifter], i)
+		s.received[giftee]++
+		if s.feasible(slot+1) && s.assign(slot+1) {
+			return true
+		}
+		s.chosen[gifter] = s.chosen[gifter][:len(s.chosen[gifter])-1]
+		s.received[giftee]--
+	}
+	return false
+}
+
+// feasible checks that everyone still short of gifts has enough gifters
+// left who are allowed to buy for them.
+func (s *pairingSolver) feasible(slot int) bool {
+	current := slot / s.n
+	needed := make(map[string]int, len(s.order))
+	for _, giftee := range s.order {
+		if n := s.n - s.received[giftee]; n > 0 {
+			needed[giftee] = n
+		}
+	}
+	for g := current; g < len(s.order); g++ {
+		gifter := s.order[g]
+		chosen := s.chosen[gifter]
+		if len(chosen) == s.n {
+			continue
+		}
+		start := 0
+		if len(chosen) > 0 {
+			start = chosen[len(chosen)-1] + 1
+		}
+		open := 0
+		for _, giftee := range s.candidates[gifter][start:] {
+			if s.received[giftee] < s.n {
+				open++
+			}
+			if needed[giftee] > 0 {
+				needed[giftee]--
+			}
+		}
+		if open < s.n-len(chosen) {
+			return false
+		}
+	}
+	for _, n := range needed {
+		if n > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func shuffle(names []string) {
+	rand.Shuffle(len(names), func(i, j int) {
+		names[i], names[j] = names[j], names[i]
+	})
+}
+
+// canGive reports whether gifter may be asked to buy for giftee.
+func canGive(gifter, giftee Participant) bool {
+	return exclusion(gifter, giftee) == ""
+}
+
+// exclusion gives the reason gifter can't buy for giftee, or an empty
+// string if they can.
+func exclusion(gifter, giftee Participant) string {
+	switch {
+	case gifter.Name == giftee.Name:
+		return "nobody buys for themselves"
+	case gifter.Partner == giftee.Name:
+		return giftee.Name + " is their partner"
+	case slices.Contains(gifter.Exclusions, giftee.Name):
+		return "they asked not to buy for " + giftee.Name
+	}
+	return ""
+}
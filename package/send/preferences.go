@@ -0,0 +1,101 @@
+package send
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Preference is a soft rule. Every pairing it matches adds Weight to the
+// score of a draw; negative weights discourage a pairing without forbidding
+// it.
+type Preference struct {
+	Name   string
+	Weight int
+	Match  func(gifter, giftee Participant) bool
+}
+
+// Preferences is the scoring model used when pairing. The draw with the
+// highest total score that still honours every hard constraint is chosen.
+type Preferences []Preference
+
+// Score totals the weight of every preference the pairing matches.
+func (p Preferences) Score(gifter, giftee Participant) int {
+	score := 0
+	for _, pref := range p {
+		if pref.Match(gifter, giftee) {
+			score += pref.Weight
+		}
+	}
+	return score
+}
+
+// CrossDepartment prefers pairing people from different departments.
+func CrossDepartment(weight int) Preference {
+	return Preference{
+		Name:   "cross-department",
+		Weight: weight,
+		Match: func(gifter, giftee Participant) bool {
+			return gifter.Department != "" && giftee.Department != "" &&
+				!strings.EqualFold(gifter.Department, giftee.Department)
+		},
+	}
+}
+
+// ManagerReport scores pairing a manager with one of their direct reports,
+// in either direction. It is meant to be given a negative weight.
+func ManagerReport(weight int) Preference {
+	return Preference{
+		Name:   "manager/report",
+		Weight: weight,
+		Match: func(gifter, giftee Participant) bool {
+			return (gifter.Manager != "" && gifter.Manager == giftee.Name) ||
+				(giftee.Manager != "" && giftee.Manager == gifter.Name)
+		},
+	}
+}
+
+// SameCountry prefers pairing people who ship to the same country.
+func SameCountry(weight int) Preference {
+	return Preference{
+		Name:   "same-country",
+		Weight: weight,
+		Match: func(gifter, giftee Participant) bool {
+			return gifter.Country != "" && strings.EqualFold(gifter.Country, giftee.Country)
+		},
+	}
+}
+
+// PairingScore breaks the score of a draw down by preference.
+type PairingScore struct {
+	Total   int
+	Matches map[string]int
+	prefs   Preferences
+}
+
+func scorePairs(a Assignment, p Participants, prefs Preferences) PairingScore {
+	score := PairingScore{Matches: make(map[string]int, len(prefs)), prefs: prefs}
+	for _, pair := range a.Pairs {
+		gifter, giftees := pair.lookup(p)
+		for _, giftee := range giftees {
+			for _, pref := range prefs {
+				if pref.Match(gifter, giftee) {
+					score.Matches[pref.Name]++
+					score.Total += pref.Weight
+				}
+			}
+		}
+	}
+	return score
+}
+
+// WriteTo writes a short human readable summary of the score.
+func (s PairingScore) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pairing score: %d\n", s.Total)
+	for _, pref := range s.prefs {
+		fmt.Fprintf(&b, "  %s: %d pairings x %d = %d\n", pref.Name, s.Matches[pref.Name], pref.Weight, s.Matches[pref.Name]*pref.Weight)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
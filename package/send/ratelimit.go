@@ -0,0 +1,104 @@
+package send
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limits how many messages are sent in a period, e.g. 10 a second
+// or 100 a minute. The zero Rate is unlimited.
+type Rate struct {
+	Messages int
+	Per      time.Duration
+}
+
+// ParseRate reads a rate such as "10/s", "100/m" or "500/h". A bare number
+// is messages per second and an empty string is unlimited.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Rate{}, nil
+	}
+	count, unit, _ := strings.Cut(s, "/")
+	messages, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || messages < 1 {
+		return Rate{}, fmt.Errorf("invalid rate %q, the number of messages must be a whole number above zero", s)
+	}
+	per := time.Second
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "", "s", "sec", "second":
+	case "m", "min", "minute":
+		per = time.Minute
+	case "h", "hour":
+		per = time.Hour
+	default:
+		return Rate{}, fmt.Errorf("invalid rate %q, use messages per s, m or h, e.g. 10/s", s)
+	}
+	return Rate{Messages: messages, Per: per}, nil
+}
+
+func (r Rate) String() string {
+	if r.Messages == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d per %s", r.Messages, r.Per)
+}
+
+// tokenBucket hands out one token every interval. It holds a single
+// token, so messages are spread evenly rather than sent in bursts that
+// providers throttle.
+type tokenBucket struct {
+	mu       sync.Mutex
+	interval time.Duration
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+}
+
+// newTokenBucket returns nil for an unlimited rate. The bucket starts full
+// so the first message goes out straight away.
+func newTokenBucket(r Rate) *tokenBucket {
+	if r.Messages < 1 || r.Per <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		interval: r.Per / time.Duration(r.Messages),
+		tokens:   1,
+		now:      time.Now,
+	}
+}
+
+// wait blocks until a token is free or ctx is done. A nil bucket never
+// blocks.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return ctx.Err()
+	}
+	for {
+		b.mu.Lock()
+		now := b.now()
+		if !b.last.IsZero() {
+			b.tokens = min(1, b.tokens+float64(now.Sub(b.last))/float64(b.interval))
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return ctx.Err()
+		}
+		delay := time.Duration((1 - b.tokens) * float64(b.interval))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package send
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{in: "", want: Rate{}},
+		{in: "10", want: Rate{Messages: 10, Per: time.Second}},
+		{in: "10/s", want: Rate{Messages: 10, Per: time.Second}},
+		{in: "100/m", want: Rate{Messages: 100, Per: time.Minute}},
+		{in: " 500 / hour ", want: Rate{Messages: 500, Per: time.Hour}},
+		{in: "0/s", wantErr: true},
+		{in: "ten/s", wantErr: true},
+		{in: "10/day", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRate(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(Rate{Messages: 2, Per: time.Second})
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := b.wait(ctx); err != nil {
+		t.Fatalf("first wait() error = %v", err)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if err := b.wait(ctx); err != nil {
+		t.Fatalf("wait() after one interval error = %v", err)
+	}
+
+	// Without time passing the next token is an interval away.
+	cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := b.wait(cancelled); err == nil {
+		t.Errorf("wait() = nil, want it to block until the context is done")
+	}
+
+	if err := (*tokenBucket)(nil).wait(ctx); err != nil {
+		t.Errorf("unlimited wait() error = %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package send
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+)
+
+// Drop takes someone out of the draw with as few changes as possible: the
+// people buying for them buy for their giftees instead. If that would break
+// an exclusion, a gifter swaps giftees with someone else. It returns
+// everyone whose assignment changed, who need to be sent it again.
+func (d *Draw) Drop(participants Participants, name string) ([]string, error) {
+	giftees, ok := d.Assignment.Giftees(name)
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the draw", name)
+	}
+	if len(d.Assignment.Pairs)-1 <= d.Assignment.GiftsPerPerson {
+		return nil, fmt.Errorf("without %s there aren't enough people left to buy %d gift(s) each", name, d.Assignment.GiftsPerPerson)
+	}
+	for _, pair := range d.Assignment.Pairs {
+		if _, ok := participants[pair.Gifter]; !ok && pair.Gifter != name {
+			return nil, fmt.Errorf("%s is in the draw but not the participants file", pair.Gifter)
+		}
+	}
+
+	pairs := d.Assignment.pairs()
+	delete(pairs, name)
+	var gifters []string
+	for gifter, names := range pairs {
+		if slices.Contains(names, name) {
+			gifters = append(gifters, gifter)
+			pairs[gifter] = slices.DeleteFunc(names, func(n string) bool { return n == name })
+		}
+	}
+	slices.Sort(gifters)
+	fits := func(gifter, giftee string) bool {
+		return !slices.Contains(pairs[gifter], giftee) && exclusion(participants[gifter], participants[giftee]) == ""
+	}
+
+	// Try every way of handing the giftees to the gifters first
+	used := make([]bool, len(giftees))
+	var match func(i int) bool
+	match = func(i int) bool {
+		if i == len(gifters) {
+			return true
+		}
+		for j, giftee := range giftees {
+			if used[j] || !fits(gifters[i], giftee) {
+				continue
+			}
+			used[j] = true
+			pairs[gifters[i]] = append(pairs[gifters[i]], giftee)
+			if match(i + 1) {
+				return true
+			}
+			used[j] = false
+			pairs[gifters[i]] = pairs[gifters[i]][:len(pairs[gifters[i]])-1]
+		}
+		return false
+	}
+	changed := slices.Clone(gifters)
+	if !match(0) {
+		// Otherwise swap with people whose giftees suit the gifters, trying
+		// every giftee and swap until everyone fits
+		others := slices.Sorted(maps.Keys(pairs))
+		var swap func(i int) bool
+		swap = func(i int) bool {
+			if i == len(gifters) {
+				return true
+			}
+			gifter := gifters[i]
+			for k, giftee := range giftees {
+				if used[k] {
+					continue
+				}
+				used[k] = true
+				if fits(gifter, giftee) {
+					pairs[gifter] = append(pairs[gifter], giftee)
+					if swap(i + 1) {
+						return true
+					}
+					pairs[gifter] = pairs[gifter][:len(pairs[gifter])-1]
+				}
+				for _, other := range others {
+					if other == gifter {
+						continue
+					}
+					for j, theirs := range pairs[other] {
+						if !fits(gifter, theirs) || !fits(other, giftee) {
+							continue
+						}
+						pairs[other][j] = giftee
+						pairs[gifter] = append(pairs[gifter], theirs)
+						changed = append(changed, other)
+						if swap(i + 1) {
+							return true
+						}
+						changed = changed[:len(changed)-1]
+						pairs[gifter] = pairs[gifter][:len(pairs[gifter])-1]
+						pairs[other][j] = theirs
+					}
+				}
+				used[k] = false
+			}
+			return false
+		}
+		if !swap(0) {
+			return nil, fmt.Errorf("the people buying for %s can't be given new giftees without breaking an exclusion, draw again instead", name)
+		}
+	}
+
+	slices.Sort(changed)
+	changed = slices.Compact(changed)
+	for _, gifter := range changed {
+		d.Revise(gifter)
+	}
+	d.setPairs(pairs)
+	return changed, nil
+}
+
+// Add splices someone into the draw with as few changes as possible: for
+// each gift they give and receive, one gifter who was buying for someone
+// else buys for them instead, and they buy for that person. The pairs to
+// break are picked at random from those that keep every exclusion. It
+// returns everyone who needs sending their assignment, including the new
+// participant.
+func (d *Draw) Add(participants Participants, name string) ([]string, error) {
+	if _, ok := d.Assignment.Giftees(name); ok {
+		return nil, fmt.Errorf("%s is already in the draw", name)
+	}
+	for _, p := range d.Assignment.Pairs {
+		if _, ok := participants[p.Gifter]; !ok {
+			return nil, fmt.Errorf("%s is in the draw but not the participants file", p.Gifter)
+		}
+	}
+	newcomer, ok := participants[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the participants file", name)
+	}
+	n := max(d.Assignment.GiftsPerPerson, 1)
+
+	type pair struct{ gifter, giftee string }
+	var candidates []pair
+	for _, p := range d.Assignment.Pairs {
+		if exclusion(participants[p.Gifter], newcomer) != "" {
+			continue
+		}
+		for _, giftee := range p.Giftees {
+			if exclusion(newcomer, participants[giftee]) == "" {
+				candidates = append(candidates, pair{p.Gifter, giftee})
+			}
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+	// Pick n pairs with different gifters and different giftees
+	var picked []pair
+	var pick func(from int) bool
+	pick = func(from int) bool {
+		if len(picked) == n {
+			return true
+		}
+		for i := from; i < len(candidates); i++ {
+			c := candidates[i]
+			if slices.ContainsFunc(picked, func(p pair) bool { return p.gifter == c.gifter || p.giftee == c.giftee }) {
+				continue
+			}
+			picked = append(picked, c)
+			if pick(i + 1) {
+				return true
+			}
+			picked = picked[:len(picked)-1]
+		}
+		return false
+	}
+	if !pick(0) {
+		return nil, fmt.Errorf("%s can't be added without breaking an exclusion, draw again instead", name)
+	}
+
+	pairs := d.Assignment.pairs()
+	changed := []string{name}
+	for _, p := range picked {
+		i := slices.Index(pairs[p.gifter], p.giftee)
+		pairs[p.gifter][i] = name
+		pairs[name] = append(pairs[name], p.giftee)
+		changed = append(changed, p.gifter)
+		d.Revise(p.gifter)
+	}
+	slices.Sort(changed)
+	// Anything they were sent before they dropped out is out of date
+	d.Revise(name)
+	d.setPairs(pairs)
+	return changed, nil
+}
+
+// setPairs replaces who buys for whom, keeping the assignment in order.
+func (d *Draw) setPairs(pairs map[string][]string) {
+	d.Assignment = newAssignment(pairs, d.Assignment.GiftsPerPerson)
+	d.Assignment.Draw = d.ID
+}
@@ -0,0 +1,164 @@
+package send
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testDraw reads pairs written as gifter>giftee,giftee ...
+func testDraw(t *testing.T, pairs string, exclusions map[string][]string) (*Draw, Participants) {
+	t.Helper()
+	d := &Draw{ID: "a"}
+	m := map[string][]string{}
+	participants := Participants{}
+	for _, pair := range strings.Fields(pairs) {
+		gifter, giftees, _ := strings.Cut(pair, ">")
+		m[gifter] = strings.Split(giftees, ",")
+		d.Assignment.GiftsPerPerson = len(m[gifter])
+		participants[gifter] = Participant{Name: gifter, Exclusions: exclusions[gifter]}
+	}
+	d.setPairs(m)
+	return d, participants
+}
+
+// checkDraw checks everyone gives and receives GiftsPerPerson gifts and
+// every pair keeps the exclusions.
+func checkDraw(t *testing.T, d *Draw, participants Participants) {
+	t.Helper()
+	n := d.Assignment.GiftsPerPerson
+	received := map[string]int{}
+	for _, pair := range d.Assignment.Pairs {
+		if len(pair.Giftees) != n || len(slices.Compact(slices.Clone(pair.Giftees))) != len(pair.Giftees) {
+			t.Errorf("%s buys for %v", pair.Gifter, pair.Giftees)
+		}
+		for _, giftee := range pair.Giftees {
+			received[giftee]++
+			if why := exclusion(participants[pair.Gifter], participants[giftee]); why != "" {
+				t.Errorf("%s buys for %s: %s", pair.Gifter, giftee, why)
+			}
+		}
+	}
+	for _, pair := range d.Assignment.Pairs {
+		if received[pair.Gifter] != n {
+			t.Errorf("%s gets %d gifts, want %d", pair.Gifter, received[pair.Gifter], n)
+		}
+	}
+	if d.Assignment.Draw != d.ID {
+		t.Errorf("the assignment is from draw %q, want %q", d.Assignment.Draw, d.ID)
+	}
+}
+
+func formatPairs(d *Draw) string {
+	var got []string
+	for _, pair := range d.Assignment.Pairs {
+		got = append(got, pair.Gifter+">"+strings.Join(pair.Giftees, ","))
+	}
+	return strings.Join(got, " ")
+}
+
+func TestDraw_Drop(t *testing.T) {
+	tests := []struct {
+		name string
+		// pairs is gifter>giftee,giftee ...
+		pairs       string
+		exclusions  map[string][]string
+		drop        string
+		wantChanged []string
+		wantPairs   string
+		wantErr     bool
+	}{
+		{name: "Gifter takes the dropout's giftee", pairs: "A>B B>C C>D D>A", drop: "C", wantChanged: []string{"B"}, wantPairs: "A>B B>D D>A"},
+		{name: "Two people swapping", pairs: "A>B B>A C>D D>C", drop: "D", wantChanged: []string{"A", "C"}},
+		{name: "Exclusion forces a swap", pairs: "A>B B>C C>D D>E E>A", exclusions: map[string][]string{"B": {"D"}}, drop: "C", wantChanged: []string{"B", "E"}, wantPairs: "A>B B>A D>E E>D"},
+		{name: "Several gifts each", pairs: "A>B,C B>C,D C>D,E D>E,A E>A,B", drop: "C"},
+		{
+			name:        "First swap tried doesn't work",
+			pairs:       "A>B,C B>C,E C>E,D D>A,B E>D,A",
+			exclusions:  map[string][]string{"E": {"B"}},
+			drop:        "D",
+			wantChanged: []string{"B", "C", "E"},
+			wantPairs:   "A>B,C B>A,E C>B,E E>A,C",
+		},
+		{name: "Not in the draw", pairs: "A>B B>C C>A", drop: "Z", wantErr: true},
+		{name: "Too few left", pairs: "A>B B>A", drop: "A", wantErr: true},
+		{name: "No way round the exclusions", pairs: "A>B B>C C>A", exclusions: map[string][]string{"A": {"C"}}, drop: "B", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, participants := testDraw(t, tt.pairs, tt.exclusions)
+			changed, err := d.Drop(participants, tt.drop)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Draw.Drop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.wantChanged != nil && !slices.Equal(changed, tt.wantChanged) {
+				t.Errorf("Draw.Drop() changed %v, want %v", changed, tt.wantChanged)
+			}
+			for _, gifter := range changed {
+				if d.Revisions[gifter] != 1 {
+					t.Errorf("%s's revision is %d, want 1", gifter, d.Revisions[gifter])
+				}
+			}
+			if _, ok := d.Assignment.Giftees(tt.drop); ok {
+				t.Errorf("%s is still in the draw", tt.drop)
+			}
+			checkDraw(t, d, participants)
+			if got := formatPairs(d); tt.wantPairs != "" && got != tt.wantPairs {
+				t.Errorf("Draw.Drop() left %s, want %s", got, tt.wantPairs)
+			}
+		})
+	}
+}
+
+func TestDraw_Add(t *testing.T) {
+	tests := []struct {
+		name       string
+		pairs      string
+		exclusions map[string][]string
+		add        string
+		wantPairs  string
+		wantErr    bool
+	}{
+		{name: "One pair is split", pairs: "A>B B>C C>A", add: "D"},
+		{name: "Exclusions pick the pair", pairs: "A>B B>C C>A", exclusions: map[string][]string{"D": {"B"}, "C": {"D"}}, add: "D", wantPairs: "A>B B>D C>A D>C"},
+		{name: "Several gifts each", pairs: "A>B,C B>C,D C>D,A D>A,B", add: "E"},
+		{name: "Already in the draw", pairs: "A>B B>A", add: "A", wantErr: true},
+		{name: "Not in the participants", pairs: "A>B B>A", add: "Z", wantErr: true},
+		{name: "Excluded from everyone", pairs: "A>B B>A", exclusions: map[string][]string{"C": {"A", "B"}}, add: "C", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, participants := testDraw(t, tt.pairs, tt.exclusions)
+			if tt.add != "Z" {
+				if _, ok := participants[tt.add]; !ok {
+					participants[tt.add] = Participant{Name: tt.add, Exclusions: tt.exclusions[tt.add]}
+				}
+			}
+			before := d.Assignment.pairs()
+			changed, err := d.Add(participants, tt.add)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Draw.Add() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			checkDraw(t, d, participants)
+			if got := formatPairs(d); tt.wantPairs != "" && got != tt.wantPairs {
+				t.Errorf("Draw.Add() left %s, want %s", got, tt.wantPairs)
+			}
+			// Only the newcomer and the gifters who now buy for them change
+			var want []string
+			for _, pair := range d.Assignment.Pairs {
+				if !slices.Equal(before[pair.Gifter], pair.Giftees) {
+					want = append(want, pair.Gifter)
+				}
+			}
+			if !slices.Equal(changed, want) || len(changed) != d.Assignment.GiftsPerPerson+1 {
+				t.Errorf("Draw.Add() changed %v, want %v", changed, want)
+			}
+		})
+	}
+}
@@ -2,16 +2,27 @@ package send
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"text/template"
 )
 
-type Emailer interface {
-	SendEmail(gifter, giftee Participant, emailTemplate *Email) error
+// Notifier tells a gifter who they are buying for, in one message listing
+// all of their giftees. The message is rendered from emailTemplate whatever
+// the channel, so chat and sms notifiers use its subject and body too.
+// Notifiers stop waiting on their service when ctx is done.
+type Notifier interface {
+	Notify(ctx context.Context, gifter Participant, giftees []Participant, emailTemplate *Email) (string, error)
 }
 
 type ParticipantLoader interface {
-	LoadParticipants(path string) (Participants, error)
+	LoadParticipants(ctx context.Context, path string) (Participants, error)
 }
 
 type Email struct {
@@ -19,107 +30,452 @@ type Email struct {
 	Body        *template.Template
 	SenderName  string
 	SenderEmail string
+	// Attachments are sent with every email. Notifiers for other channels
+	// leave them out.
+	Attachments []Attachment
 }
 
-func (e Email) Render(gifter, giftee Participant) (string, error) {
+// Attachment is a file sent along with an email, such as a calendar invite.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Render executes the body template for a gifter. Templates can use
+// .Gifter, .Giftees and .GifteeNames; .Giftee is the first giftee, for
+// templates written before gifters could have more than one.
+func (e Email) Render(gifter Participant, giftees ...Participant) (string, error) {
 	var buff bytes.Buffer
-	err := e.Body.Execute(&buff, struct {
-		Gifter Participant
-		Giftee Participant
+	data := struct {
+		Gifter      Participant
+		Giftee      Participant
+		Giftees     []Participant
+		GifteeNames string
 	}{
-		Gifter: gifter,
-		Giftee: giftee,
-	})
+		Gifter:      gifter,
+		Giftees:     giftees,
+		GifteeNames: joinNames(giftees),
+	}
+	if len(giftees) > 0 {
+		data.Giftee = giftees[0]
+	}
+	err := e.Body.Execute(&buff, data)
 	if err != nil {
 		return "", fmt.Errorf("error executing template: %v", err)
 	}
 	return buff.String(), nil
 }
 
+func joinNames(participants []Participant) string {
+	names := make([]string, 0, len(participants))
+	for _, p := range participants {
+		names = append(names, p.Name)
+	}
+	return joinList(names)
+}
+
 type Sender struct {
-	Emailer           Emailer
+	// Notifier delivers to participants who don't list any channels.
+	Notifier          Notifier
 	ParticipantLoader ParticipantLoader
 	EmailTemplate     *Email
+	// Notifiers delivers by channel name, e.g. "email" or "sms", to
+	// participants who list the channels they want.
+	Notifiers map[string]Notifier
+	// Templates replaces EmailTemplate for messages sent on a channel.
+	Templates map[string]*Email
+	// GiftsPerPerson is how many people each participant buys for. Zero
+	// means one.
+	GiftsPerPerson int
+	// Preferences scores possible pairings. When set, the best scoring draw
+	// is used instead of a uniformly random one.
+	Preferences Preferences
+	// Concurrency is how many messages are sent at once. Zero means one.
+	Concurrency int
+	// Rate limits how fast messages are sent. The zero Rate is unlimited.
+	Rate Rate
+	// Output receives a summary of the draw, such as its score. Nil
+	// discards it.
+	Output io.Writer
+	// Draw is the assignment to send. When nil a new one is drawn.
+	Draw *Draw
+	// Journal records each delivery from Draw, and gifters it already has
+	// are skipped unless their assignment has been revised since. It needs
+	// a Draw.
+	Journal *Journal
+	// ResendPending sends to gifters the journal has as pending, whose
+	// message was on its way when an earlier run stopped, instead of
+	// reporting them as maybe sent.
+	ResendPending bool
+	// Logger records each delivery, by gifter only so it never gives away
+	// an assignment. Nil uses slog.Default().
+	Logger *slog.Logger
+	// Delivered, when set, is called after each assignment is sent, with
+	// the error if it wasn't. Calls are never made at the same time.
+	Delivered func(d Delivery, err error)
 }
 
 type Participant struct {
-	Name      string
-	Email     string
+	Name  string
+	Email string
+	// Handle identifies the participant on chat services, e.g. a Slack
+	// member id or a Matrix user id.
+	Handle string
+	// Phone is the participant's mobile number in international format,
+	// e.g. +15555550123.
+	Phone string
+	// Channels lists how the participant wants to be told their assignment,
+	// most preferred first. Later channels are only used if earlier ones
+	// fail.
+	Channels  []string
 	Interests []string
+	Wishlist  []WishlistItem
 	Partner   string
+	// Exclusions are the names of people the participant mustn't buy for,
+	// as well as their partner.
+	Exclusions []string
+	Department string
+	// Manager is the name of the participant's manager, if they take part.
+	Manager string
+	// Country is where the participant's gift is shipped to.
+	Country string
+	// Address is the participant's shipping address.
+	Address string
 }
 
 type Participants map[string]Participant
 
-func (s *Sender) Send(path string) error {
-	participants, err := s.ParticipantLoader.LoadParticipants(path)
+// Send draws and delivers every assignment on Concurrency workers, no
+// faster than Rate. Once ctx is done no more messages are started and those
+// in flight are cancelled. If anything wasn't delivered the error wraps a
+// *DeliveryError saying who was and wasn't sent their assignment.
+func (s *Sender) Send(ctx context.Context, path string) error {
+	return s.send(ctx, path, nil)
+}
+
+// Resend sends the named gifters their assignment from Draw again, whether
+// or not the journal has it. Revise each of them first, so the new
+// messages aren't dropped as repeats.
+func (s *Sender) Resend(ctx context.Context, path string, gifters ...string) error {
+	if s.Draw == nil {
+		return fmt.Errorf("only a saved draw can be sent again")
+	}
+	if len(gifters) == 0 {
+		return nil
+	}
+	assignment := s.Draw.Assignment
+	for _, gifter := range gifters {
+		if _, ok := assignment.Giftees(gifter); !ok {
+			return fmt.Errorf("%s is not in the draw", gifter)
+		}
+	}
+	return s.send(ctx, path, gifters)
+}
+
+// send delivers the assignments for only, or everyone the journal doesn't
+// have when only is nil.
+func (s *Sender) send(ctx context.Context, path string, only []string) error {
+	if s.Journal != nil && s.Draw == nil {
+		return fmt.Errorf("a journal can only record a saved draw")
+	}
+	participants, err := s.ParticipantLoader.LoadParticipants(ctx, path)
 	if err != nil {
-		return fmt.Errorf("error parsing participants: %v", err)
+		return fmt.Errorf("%w: %v", ErrParticipants, err)
+	}
+	logger := s.logger()
+	var assignment Assignment
+	if s.Draw != nil {
+		// Participants may have new email addresses or wishlists since the
+		// draw was made, but everyone in it must still be taking part
+		assignment = s.Draw.Assignment
+		err = assignment.check(participants)
+	} else {
+		assignment, err = s.pair(participants)
 	}
-	pairs, err := pairParticipants(participants)
 	if err != nil {
 		return fmt.Errorf("error pairing participants: %v", err)
 	}
-	for gifter, giftee := range pairs {
-		err = s.Emailer.SendEmail(*gifter, *giftee, s.EmailTemplate)
+	if s.Notifier == nil {
+		// Checked before anything is sent, rather than failing part way
+		var unreachable []string
+		for _, pair := range assignment.Pairs {
+			if len(participants[pair.Gifter].Channels) == 0 && (only == nil || slices.Contains(only, pair.Gifter)) {
+				unreachable = append(unreachable, pair.Gifter)
+			}
+		}
+		if len(unreachable) > 0 {
+			return fmt.Errorf("%s %w", joinList(unreachable), ErrNoNotifier)
+		}
+	}
+
+	type job struct {
+		gifter  Participant
+		giftees []Participant
 	}
-	if err != nil {
-		return fmt.Errorf("error sending email: %v", err)
+	var (
+		mu     sync.Mutex
+		report = &DeliveryError{Failed: map[string]error{}}
+		wg     sync.WaitGroup
+		bucket = newTokenBucket(s.Rate)
+	)
+	jobs := make(chan job)
+	go func() {
+		defer close(jobs)
+		for _, pair := range assignment.Pairs {
+			if only != nil {
+				if !slices.Contains(only, pair.Gifter) {
+					continue
+				}
+			} else if s.Journal != nil {
+				// A revised assignment is sent again
+				revision := s.Draw.Revisions[pair.Gifter]
+				if d, ok := s.Journal.Delivery(assignment.Draw, pair.Gifter); ok && d.Revision >= revision {
+					logger.Debug("already sent", "gifter", pair.Gifter, "draw", assignment.Draw)
+					continue
+				}
+				// An earlier run was stopped while sending this one
+				if d, ok := s.Journal.Pending(assignment.Draw, pair.Gifter); ok && d.Revision >= revision && !s.ResendPending {
+					logger.Warn("assignment may have been sent", "gifter", pair.Gifter, "draw", assignment.Draw)
+					mu.Lock()
+					report.MaybeSent = append(report.MaybeSent, pair.Gifter)
+					mu.Unlock()
+					continue
+				}
+			}
+			gifter, giftees := pair.lookup(participants)
+			jobs <- job{gifter: gifter, giftees: giftees}
+		}
+	}()
+
+	for range max(s.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				var d Delivery
+				err := bucket.wait(ctx)
+				if err == nil && s.Journal != nil {
+					// Written first, so a run that stops now knows this
+					// message may have gone
+					if err = s.Journal.Record(Delivery{Draw: s.Draw.ID, Gifter: j.gifter.Name, Revision: s.Draw.Revisions[j.gifter.Name], Pending: true}); err != nil {
+						err = fmt.Errorf("%s wasn't sent their assignment as it couldn't be journaled: %v", j.gifter.Name, err)
+					}
+				}
+				started := err == nil
+				if started {
+					d, err = s.deliver(ctx, s.Draw, j.gifter, j.giftees, nil)
+					if err == nil && s.Journal != nil {
+						if err = s.Journal.Record(d); err != nil {
+							err = fmt.Errorf("%s was sent their assignment but it wasn't journaled: %v", j.gifter.Name, err)
+						}
+					}
+				}
+				mu.Lock()
+				if s.Delivered != nil {
+					d.Gifter = j.gifter.Name
+					s.Delivered(d, err)
+				}
+				switch {
+				case err != nil && !started && ctx.Err() != nil:
+					report.Unsent = append(report.Unsent, j.gifter.Name)
+				case err != nil && ctx.Err() != nil:
+					// Stopped while the message was on its way, so the
+					// provider may have taken it
+					report.MaybeSent = append(report.MaybeSent, j.gifter.Name)
+					logger.Warn("assignment may have been sent", "gifter", j.gifter.Name, "err", err)
+				case err != nil:
+					report.Failed[j.gifter.Name] = err
+					logger.Warn("assignment not sent", "gifter", j.gifter.Name, "err", err)
+				default:
+					report.Sent = append(report.Sent, j.gifter.Name)
+					logger.Debug("assignment sent", "gifter", j.gifter.Name, "channel", d.Channel, "message_id", d.MessageID)
+				}
+				mu.Unlock()
+			}
+		}()
 	}
-	return nil
+	wg.Wait()
+	logger.Info("finished sending", "sent", len(report.Sent), "failed", len(report.Failed), "unsent", len(report.Unsent), "maybe_sent", len(report.MaybeSent))
+
+	if len(report.Failed) == 0 && len(report.Unsent) == 0 && len(report.MaybeSent) == 0 {
+		return nil
+	}
+	slices.Sort(report.Sent)
+	slices.Sort(report.Unsent)
+	slices.Sort(report.MaybeSent)
+	report.Cancelled = ctx.Err()
+	return fmt.Errorf("error sending email: %w", report)
 }
 
-type pairedParticipants map[*Participant]*Participant
+var (
+	// ErrParticipants is returned when the participants can't be loaded.
+	ErrParticipants = errors.New("error parsing participants")
+	// ErrNoNotifier is returned when someone lists no channels and there
+	// is no default notifier to reach them.
+	ErrNoNotifier = errors.New("list no channels and the default channel isn't set up")
+)
 
-func pairParticipants(p Participants) (pairedParticipants, error) {
+// DeliveryError reports which gifters were sent their assignment when some
+// weren't.
+type DeliveryError struct {
+	Sent []string
+	// Failed holds the error for each gifter whose delivery failed.
+	Failed map[string]error
+	// Unsent lists gifters who were skipped because sending was stopped.
+	Unsent []string
+	// MaybeSent lists gifters whose message was on its way when sending
+	// was stopped, in this run or, going by the journal, an earlier one.
+	// The provider may have accepted it, so check before sending it again.
+	MaybeSent []string
+	// Cancelled is why sending was stopped, if it was.
+	Cancelled error
+}
 
-	names := make(map[string]struct{})
-	for name, _ := range p {
-		names[name] = struct{}{}
+func (e *DeliveryError) Error() string {
+	var parts []string
+	names := make([]string, 0, len(e.Failed))
+	for name := range e.Failed {
+		names = append(names, name)
 	}
-	pairs := make(pairedParticipants)
-	// Since this is non-deterministic, we'll try a few times
-	var err error
-	for tries := 10; tries >= 0; tries-- {
-		for _, participant := range p {
-			var gifteeName string
-			gifteeName, err = getName(participant, names)
-			// don't continue if we can't find a name
-			if err != nil {
-				break
-			}
-			giftee := p[gifteeName]
-			pairs[&participant] = &giftee
-			delete(names, gifteeName)
+	slices.Sort(names)
+	for _, name := range names {
+		parts = append(parts, e.Failed[name].Error())
+	}
+	if len(e.MaybeSent) > 0 {
+		parts = append(parts, fmt.Sprintf("sending was stopped while messaging %s, check whether they got their assignment before sending it again", joinList(e.MaybeSent)))
+	}
+	if len(e.Unsent) > 0 {
+		parts = append(parts, fmt.Sprintf("stopped (%v) before sending to %s", e.Cancelled, joinList(e.Unsent)))
+	}
+	if len(e.Sent) > 0 {
+		parts = append(parts, "sent to "+joinList(e.Sent))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// deliver notifies a gifter on the first of their channels that works, or
+// with the default Notifier if they haven't chosen any. Each attempt
+// carries the draw and an idempotency key for the draw, gifter and
+// channel. A message, when given, is sent on every channel instead of the
+// channel's template, and is never treated as a repeat.
+func (s *Sender) deliver(ctx context.Context, draw *Draw, gifter Participant, giftees []Participant, message *Email) (Delivery, error) {
+	d := Delivery{Gifter: gifter.Name}
+	if draw != nil {
+		d.Draw, d.Revision = draw.ID, draw.Revisions[gifter.Name]
+	}
+	ctx = context.WithValue(ctx, drawKey{}, d.Draw)
+	keyed := func(channel string) context.Context {
+		if message != nil {
+			return ctx
 		}
-		// don't retry if we found a solution
-		if err == nil {
-			break
+		return WithIdempotencyKey(ctx, deliveryKey(d.Draw, gifter.Name, channel, d.Revision))
+	}
+	if len(gifter.Channels) == 0 {
+		if s.Notifier == nil {
+			return d, fmt.Errorf("%s %w", gifter.Name, ErrNoNotifier)
+		}
+		tmpl := s.EmailTemplate
+		if message != nil {
+			tmpl = message
+		}
+		id, err := s.Notifier.Notify(keyed(""), gifter, giftees, tmpl)
+		if err != nil {
+			return d, fmt.Errorf("%s: %w", gifter.Name, err)
+		}
+		d.MessageID = id
+		return d, nil
+	}
+	var failures channelErrors
+	for _, channel := range gifter.Channels {
+		notifier, ok := s.Notifiers[channel]
+		if !ok {
+			failures = append(failures, fmt.Errorf("%s is not set up", channel))
+			continue
+		}
+		tmpl, ok := s.Templates[channel]
+		if !ok {
+			tmpl = s.EmailTemplate
 		}
-		// return error if tries are exhausted
-		if tries == 0 {
-			return pairedParticipants{}, fmt.Errorf("error finding giftee: %v", err)
+		if message != nil {
+			tmpl = message
 		}
-		// reset names
-		for name, _ := range p {
-			names[name] = struct{}{}
+		id, err := notifier.Notify(keyed(channel), gifter, giftees, tmpl)
+		if err == nil {
+			d.Channel, d.MessageID = channel, id
+			return d, nil
+		}
+		failures = append(failures, fmt.Errorf("%s failed: %w", channel, err))
+		s.logger().Debug("channel failed", "gifter", gifter.Name, "channel", channel, "err", err)
+		if ctx.Err() != nil {
+			break
 		}
+	}
+	return d, fmt.Errorf("%s: %w", gifter.Name, failures)
+}
+
+// channelErrors are the failures of each channel a gifter listed.
+type channelErrors []error
+
+func (e channelErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
 
+func (e channelErrors) Unwrap() []error {
+	return e
+}
+
+// Message sends a one off message, such as a relayed question, to a
+// participant on the first of their channels that works.
+func (s *Sender) Message(ctx context.Context, to Participant, message *Email) (string, error) {
+	d, err := s.deliver(ctx, nil, to, nil, message)
+	return d.MessageID, err
+}
+
+func (s *Sender) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
 	}
-	return pairs, nil
+	return s.Logger
 }
 
-func getName(gifter Participant, names map[string]struct{}) (string, error) {
+type drawKey struct{}
 
-	name := ""
-	for key, _ := range names {
-		if key != gifter.Name && key != gifter.Partner {
-			name = key
-			break
-		}
+// DrawID returns the id of the draw an assignment being notified is from,
+// or "" if it isn't from a saved draw.
+func DrawID(ctx context.Context) string {
+	id, _ := ctx.Value(drawKey{}).(string)
+	return id
+}
+
+// NewDraw loads the participants and draws names without sending anything,
+// so the draw can be saved first.
+func (s *Sender) NewDraw(ctx context.Context, path string) (*Draw, error) {
+	participants, err := s.ParticipantLoader.LoadParticipants(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrParticipants, err)
+	}
+	assignment, err := s.pair(participants)
+	if err != nil {
+		return nil, fmt.Errorf("error pairing participants: %v", err)
+	}
+	return newDraw(assignment), nil
+}
+
+func (s *Sender) pair(participants Participants) (Assignment, error) {
+	if len(s.Preferences) == 0 {
+		return pairParticipants(participants, s.GiftsPerPerson)
+	}
+	assignment, err := optimisePairs(participants, s.GiftsPerPerson, s.Preferences)
+	if err != nil {
+		return Assignment{}, err
 	}
-	if name == "" {
-		return name, fmt.Errorf("no name found")
+	if s.Output != nil {
+		scorePairs(assignment, participants, s.Preferences).WriteTo(s.Output)
 	}
-	return name, nil
+	return assignment, nil
 }
@@ -1,8 +1,17 @@
 package send
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"text/template"
 )
 
 func Test_pairParticipants(t *testing.T) {
@@ -66,16 +75,35 @@ func Test_pairParticipants(t *testing.T) {
 			want:    map[string]string{},
 			wantErr: true,
 		},
+		{
+			name: "Exclusions are honoured",
+			args: args{
+				p: map[string]Participant{
+					"1": {Name: "1", Exclusions: []string{"3"}},
+					"2": {Name: "2", Exclusions: []string{"1"}},
+					"3": {Name: "3", Exclusions: []string{"2"}},
+				},
+			},
+			want: map[string]string{
+				"1": "2",
+				"2": "3",
+				"3": "1",
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := pairParticipants(tt.args.p)
+			got, err := pairParticipants(tt.args.p, 1)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("pairParticipants() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			for gifter, giftee := range got {
-				if giftee.Name != tt.want[gifter.Name] {
+			if len(got.Pairs) != len(tt.want) {
+				t.Errorf("pairParticipants() = %v, want %v", got, tt.want)
+			}
+			for _, pair := range got.Pairs {
+				if len(pair.Giftees) != 1 || pair.Giftees[0] != tt.want[pair.Gifter] {
 					t.Errorf("pairParticipants() = %v, want %v", got, tt.want)
 				}
 			}
@@ -83,9 +111,243 @@ func Test_pairParticipants(t *testing.T) {
 	}
 }
 
+func Test_pairParticipantsGiftsPerPerson(t *testing.T) {
+	couples := Participants{}
+	for i := 0; i < 5; i++ {
+		a, b := fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i)
+		couples[a] = Participant{Name: a, Partner: b}
+		couples[b] = Participant{Name: b, Partner: a}
+	}
+	tests := []struct {
+		name    string
+		p       Participants
+		n       int
+		wantErr bool
+	}{
+		{name: "One gift each", p: couples, n: 1},
+		{name: "Two gifts each", p: couples, n: 2},
+		{name: "Eight gifts each is everyone but yourself and your partner", p: couples, n: 8},
+		{name: "Nine gifts each would include a partner", p: couples, n: 9, wantErr: true},
+		{name: "More gifts than participants", p: couples, n: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pairParticipants(tt.p, tt.n)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pairParticipants() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			received := map[string]int{}
+			for _, pair := range got.Pairs {
+				gifter, giftees := pair.lookup(tt.p)
+				if len(giftees) != tt.n {
+					t.Errorf("%s gives %d gifts, want %d", gifter.Name, len(giftees), tt.n)
+				}
+				seen := map[string]bool{}
+				for _, giftee := range giftees {
+					if !canGive(gifter, giftee) {
+						t.Errorf("%s can't give to %s", gifter.Name, giftee.Name)
+					}
+					if seen[giftee.Name] {
+						t.Errorf("%s gives to %s twice", gifter.Name, giftee.Name)
+					}
+					seen[giftee.Name] = true
+					received[giftee.Name]++
+				}
+			}
+			for name := range tt.p {
+				if received[name] != tt.n {
+					t.Errorf("%s receives %d gifts, want %d", name, received[name], tt.n)
+				}
+			}
+		})
+	}
+}
+
+func Test_optimisePairs(t *testing.T) {
+	p := Participants{
+		"boss":  {Name: "boss", Department: "sales", Country: "CA"},
+		"rep":   {Name: "rep", Department: "sales", Manager: "boss", Country: "CA"},
+		"dev1":  {Name: "dev1", Department: "eng", Country: "CA"},
+		"dev2":  {Name: "dev2", Department: "eng", Partner: "dev1", Country: "US"},
+		"ops":   {Name: "ops", Department: "ops", Country: "US"},
+		"admin": {Name: "admin", Department: "ops", Country: "US"},
+	}
+	tests := []struct {
+		name  string
+		prefs Preferences
+		n     int
+		want  int
+	}{
+		{
+			name:  "Everyone can give across departments",
+			prefs: Preferences{CrossDepartment(1)},
+			n:     1,
+			want:  6,
+		},
+		{
+			name:  "Manager and report are kept apart",
+			prefs: Preferences{ManagerReport(-10)},
+			n:     2,
+			want:  0,
+		},
+		{
+			name:  "Same country is preferred over cross department",
+			prefs: Preferences{CrossDepartment(1), SameCountry(5)},
+			n:     1,
+			want:  6*5 + 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := optimisePairs(p, tt.n, tt.prefs)
+			if err != nil {
+				t.Fatalf("optimisePairs() error = %v", err)
+			}
+			received := map[string]int{}
+			for _, pair := range got.Pairs {
+				gifter, giftees := pair.lookup(p)
+				if len(giftees) != tt.n {
+					t.Errorf("%s gives %d gifts, want %d", gifter.Name, len(giftees), tt.n)
+				}
+				for _, giftee := range giftees {
+					if !canGive(gifter, giftee) {
+						t.Errorf("%s can't give to %s", gifter.Name, giftee.Name)
+					}
+					received[giftee.Name]++
+				}
+			}
+			for name := range p {
+				if received[name] != tt.n {
+					t.Errorf("%s receives %d gifts, want %d", name, received[name], tt.n)
+				}
+			}
+			if score := scorePairs(got, p, tt.prefs); score.Total != tt.want {
+				t.Errorf("optimisePairs() score = %d, want %d", score.Total, tt.want)
+			}
+		})
+	}
+}
+
+func Test_diagnose(t *testing.T) {
+	tests := []struct {
+		name            string
+		p               Participants
+		n               int
+		wantGifters     []string
+		wantGiftees     []string
+		wantSuggestions []string
+		// wantError is the whole message, when set.
+		wantError string
+	}{
+		{
+			name: "Partners with nobody else",
+			p: Participants{
+				"Fred":  {Name: "Fred", Partner: "Wilma"},
+				"Wilma": {Name: "Wilma", Partner: "Fred"},
+			},
+			n:           1,
+			wantGifters: []string{"Fred", "Wilma"},
+			wantGiftees: nil,
+			wantSuggestions: []string{
+				"remove the partner exclusion between Fred and Wilma",
+				"add another participant who can give to and receive from Fred and Wilma",
+			},
+			wantError: `Fred and Wilma can only give to each other
+because:
+  - Fred can't give to Wilma: Wilma is their partner
+  - Wilma can't give to Fred: Fred is their partner
+to fix this, try one of:
+  - remove the partner exclusion between Fred and Wilma
+  - add another participant who can give to and receive from Fred and Wilma`,
+		},
+		{
+			name: "Nobody left after exclusions",
+			p: Participants{
+				"Fred":    {Name: "Fred", Exclusions: []string{"Pebbles", "Wilma"}},
+				"Wilma":   {Name: "Wilma"},
+				"Pebbles": {Name: "Pebbles"},
+			},
+			n:           1,
+			wantGifters: []string{"Fred"},
+			wantGiftees: nil,
+			wantSuggestions: []string{
+				"allow Fred to give to Pebbles",
+				"allow Fred to give to Wilma",
+				"add another participant who can give to and receive from Fred",
+			},
+		},
+		{
+			name: "Two partners can only give to the same person",
+			p: Participants{
+				"Fred":    {Name: "Fred", Partner: "Wilma"},
+				"Wilma":   {Name: "Wilma", Partner: "Fred"},
+				"Pebbles": {Name: "Pebbles"},
+			},
+			n:           1,
+			wantGifters: []string{"Fred", "Wilma"},
+			wantGiftees: []string{"Pebbles"},
+			wantSuggestions: []string{
+				"remove the partner exclusion between Fred and Wilma",
+				"add another participant who can give to and receive from Fred and Wilma",
+			},
+		},
+		{
+			name: "Too many gifts each",
+			p: Participants{
+				"Fred":    {Name: "Fred", Partner: "Wilma"},
+				"Wilma":   {Name: "Wilma", Partner: "Fred"},
+				"Barney":  {Name: "Barney", Partner: "Betty"},
+				"Betty":   {Name: "Betty", Partner: "Barney"},
+				"Pebbles": {Name: "Pebbles"},
+			},
+			n:           3,
+			wantGifters: []string{"Barney", "Betty", "Fred", "Wilma"},
+			wantGiftees: []string{"Barney", "Betty", "Fred", "Pebbles", "Wilma"},
+			wantSuggestions: []string{
+				"remove the partner exclusion between Barney and Betty",
+				"remove the partner exclusion between Fred and Wilma",
+				"give 2 gifts each instead of 3",
+				"add another participant who can give to and receive from Barney, Betty, Fred and Wilma",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := diagnose(tt.p, tt.n)
+			pe, ok := err.(*PairingError)
+			if !ok {
+				t.Fatalf("diagnose() = %v, want a *PairingError", err)
+			}
+			if !slices.Equal(pe.Gifters, tt.wantGifters) {
+				t.Errorf("diagnose() gifters = %v, want %v", pe.Gifters, tt.wantGifters)
+			}
+			if !slices.Equal(pe.Giftees, tt.wantGiftees) {
+				t.Errorf("diagnose() giftees = %v, want %v", pe.Giftees, tt.wantGiftees)
+			}
+			if !slices.Equal(pe.Suggestions, tt.wantSuggestions) {
+				t.Errorf("diagnose() suggestions = %q, want %q", pe.Suggestions, tt.wantSuggestions)
+			}
+			if tt.wantError != "" && pe.Error() != tt.wantError {
+				t.Errorf("diagnose() error =\n%s\nwant\n%s", pe.Error(), tt.wantError)
+			}
+		})
+	}
+
+	solvable := Participants{
+		"1": {Name: "1"},
+		"2": {Name: "2"},
+	}
+	if err := diagnose(solvable, 1); err != nil {
+		t.Errorf("diagnose() = %v for a solvable draw, want nil", err)
+	}
+}
+
 type testParticpantsLoaderError struct{}
 
-func (t testParticpantsLoaderError) LoadParticipants(path string) (Participants, error) {
+func (t testParticpantsLoaderError) LoadParticipants(ctx context.Context, path string) (Participants, error) {
 	return nil, fmt.Errorf("error loading participants")
 }
 
@@ -93,7 +355,7 @@ type testParticpantsLoaderNoError struct {
 	participantError bool
 }
 
-func (t testParticpantsLoaderNoError) LoadParticipants(path string) (Participants, error) {
+func (t testParticpantsLoaderNoError) LoadParticipants(ctx context.Context, path string) (Participants, error) {
 	if t.participantError {
 		// should return an error if the participants are unmatchable
 		return map[string]Participant{
@@ -121,21 +383,72 @@ func (t testParticpantsLoaderNoError) LoadParticipants(path string) (Participant
 	}, nil
 }
 
-type testEmailerError struct{}
+type testNotifierError struct{}
 
-func (t testEmailerError) SendEmail(gifter, giftee Participant, emailTemplate *Email) error {
-	return fmt.Errorf("error sending email")
+func (t testNotifierError) Notify(ctx context.Context, gifter Participant, giftees []Participant, emailTemplate *Email) (string, error) {
+	return "", fmt.Errorf("error sending email")
 }
 
-type testEmailerNoError struct{}
+type testNotifierNoError struct{}
 
-func (t testEmailerNoError) SendEmail(gifter, giftee Participant, emailTemplate *Email) error {
-	return nil
+func (t testNotifierNoError) Notify(ctx context.Context, gifter Participant, giftees []Participant, emailTemplate *Email) (string, error) {
+	return "", nil
+}
+
+type testNotifierRecorder struct {
+	notified *[]string
+}
+
+func (t testNotifierRecorder) Notify(ctx context.Context, gifter Participant, giftees []Participant, emailTemplate *Email) (string, error) {
+	*t.notified = append(*t.notified, gifter.Name)
+	return "", nil
+}
+
+func TestSender_deliver(t *testing.T) {
+	tests := []struct {
+		name         string
+		channels     []string
+		wantNotified []string
+		wantErr      bool
+	}{
+		{name: "No channels uses the default notifier", wantNotified: []string{"default"}},
+		{name: "Primary channel is used", channels: []string{"sms", "email"}, wantNotified: []string{"sms"}},
+		{name: "Falls back when the primary fails", channels: []string{"slack", "email"}, wantNotified: []string{"email"}},
+		{name: "Falls back when the primary isn't set up", channels: []string{"matrix", "sms"}, wantNotified: []string{"sms"}},
+		{name: "Every channel fails", channels: []string{"slack", "matrix"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var defaultNotified, smsNotified, emailNotified []string
+			s := &Sender{
+				Notifier: testNotifierRecorder{notified: &defaultNotified},
+				Notifiers: map[string]Notifier{
+					"sms":   testNotifierRecorder{notified: &smsNotified},
+					"email": testNotifierRecorder{notified: &emailNotified},
+					"slack": testNotifierError{},
+				},
+				EmailTemplate: &Email{},
+			}
+			_, err := s.deliver(context.Background(), nil, Participant{Name: "1", Channels: tt.channels}, nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Sender.deliver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for channel, notified := range map[string][]string{"default": defaultNotified, "sms": smsNotified, "email": emailNotified} {
+				if len(notified) > 0 {
+					got = append(got, channel)
+				}
+			}
+			if !slices.Equal(got, tt.wantNotified) {
+				t.Errorf("Sender.deliver() notified %v, want %v", got, tt.wantNotified)
+			}
+		})
+	}
 }
 
 func TestSender_Send(t *testing.T) {
 	type fields struct {
-		Emailer           Emailer
+		Notifier          Notifier
 		ParticipantLoader ParticipantLoader
 		EmailTemplate     *Email
 	}
@@ -151,7 +464,7 @@ func TestSender_Send(t *testing.T) {
 		{
 			name: "Participant loader returns an error",
 			fields: fields{
-				Emailer:           nil,
+				Notifier:          nil,
 				ParticipantLoader: testParticpantsLoaderError{},
 				EmailTemplate:     &Email{},
 			},
@@ -159,9 +472,9 @@ func TestSender_Send(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "Emailer returns an error",
+			name: "Notifier returns an error",
 			fields: fields{
-				Emailer:           testEmailerError{},
+				Notifier:          testNotifierError{},
 				ParticipantLoader: testParticpantsLoaderNoError{},
 				EmailTemplate:     &Email{},
 			},
@@ -171,7 +484,7 @@ func TestSender_Send(t *testing.T) {
 		{
 			name: "PairParticipants returns an error",
 			fields: fields{
-				Emailer:           testEmailerNoError{},
+				Notifier:          testNotifierNoError{},
 				ParticipantLoader: testParticpantsLoaderNoError{participantError: true},
 				EmailTemplate:     &Email{},
 			},
@@ -181,7 +494,7 @@ func TestSender_Send(t *testing.T) {
 		{
 			name: "No errors",
 			fields: fields{
-				Emailer:           testEmailerNoError{},
+				Notifier:          testNotifierNoError{},
 				ParticipantLoader: testParticpantsLoaderNoError{participantError: false},
 				EmailTemplate:     &Email{},
 			},
@@ -192,13 +505,228 @@ func TestSender_Send(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Sender{
-				Emailer:           tt.fields.Emailer,
+				Notifier:          tt.fields.Notifier,
 				ParticipantLoader: tt.fields.ParticipantLoader,
 				EmailTemplate:     tt.fields.EmailTemplate,
 			}
-			if err := s.Send(tt.args.path); (err != nil) != tt.wantErr {
+			if err := s.Send(context.Background(), tt.args.path); (err != nil) != tt.wantErr {
 				t.Errorf("Sender.Send() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+type testParticipantsLoaderMany int
+
+func (n testParticipantsLoaderMany) LoadParticipants(ctx context.Context, path string) (Participants, error) {
+	p := Participants{}
+	for i := range int(n) {
+		name := fmt.Sprint(i)
+		p[name] = Participant{Name: name}
+	}
+	return p, nil
+}
+
+type testParticipantsLoader Participants
+
+func (p testParticipantsLoader) LoadParticipants(ctx context.Context, path string) (Participants, error) {
+	return Participants(p), nil
+}
+
+// testNotifierCancel stops sending after the first message.
+type testNotifierCancel struct {
+	cancel context.CancelFunc
+}
+
+func (t testNotifierCancel) Notify(ctx context.Context, gifter Participant, giftees []Participant, emailTemplate *Email) (string, error) {
+	t.cancel()
+	return "", nil
+}
+
+func TestSender_SendConcurrently(t *testing.T) {
+	t.Run("Concurrent workers send everything", func(t *testing.T) {
+		var mu sync.Mutex
+		sent := 0
+		s := &Sender{
+			Notifier:          testNotifierFunc(func() { mu.Lock(); sent++; mu.Unlock() }),
+			ParticipantLoader: testParticipantsLoaderMany(20),
+			EmailTemplate:     &Email{},
+			Concurrency:       4,
+		}
+		if err := s.Send(context.Background(), ""); err != nil {
+			t.Fatalf("Sender.Send() error = %v", err)
+		}
+		if sent != 20 {
+			t.Errorf("sent %d messages, want 20", sent)
+		}
+	})
+
+	t.Run("Cancelling reports what wasn't sent", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		s := &Sender{
+			Notifier:          testNotifierCancel{cancel: cancel},
+			ParticipantLoader: testParticipantsLoaderMany(5),
+			EmailTemplate:     &Email{},
+		}
+		err := s.Send(ctx, "")
+		var report *DeliveryError
+		if !errors.As(err, &report) {
+			t.Fatalf("Sender.Send() error = %v, want a DeliveryError", err)
+		}
+		if len(report.Sent) != 1 || len(report.Unsent) != 4 || len(report.Failed) != 0 {
+			t.Errorf("sent %v, unsent %v, failed %v; want 1 sent and 4 unsent", report.Sent, report.Unsent, report.Failed)
+		}
+		if !errors.Is(report.Cancelled, context.Canceled) {
+			t.Errorf("Cancelled = %v, want context.Canceled", report.Cancelled)
+		}
+	})
+
+	t.Run("Cancelling mid send reports maybe sent", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		s := &Sender{
+			Notifiers: map[string]Notifier{"sms": testNotifierInFlight{cancel: cancel}},
+			ParticipantLoader: testParticipantsLoader{
+				"Fred":  {Name: "Fred", Channels: []string{"sms"}},
+				"Wilma": {Name: "Wilma", Channels: []string{"sms"}},
+			},
+			EmailTemplate: &Email{},
+		}
+		var delivered error
+		s.Delivered = func(d Delivery, err error) {
+			if d.Gifter == "Fred" {
+				delivered = err
+			}
+		}
+		err := s.Send(ctx, "")
+		var report *DeliveryError
+		if !errors.As(err, &report) {
+			t.Fatalf("Sender.Send() error = %v, want a DeliveryError", err)
+		}
+		if !slices.Equal(report.MaybeSent, []string{"Fred"}) || !slices.Equal(report.Unsent, []string{"Wilma"}) || len(report.Failed) != 0 {
+			t.Errorf("maybe sent %v, unsent %v, failed %v; want Fred maybe sent and Wilma unsent", report.MaybeSent, report.Unsent, report.Failed)
+		}
+		if !errors.Is(delivered, context.Canceled) {
+			t.Errorf("Sender.Delivered() error = %v, want it to wrap context.Canceled", delivered)
+		}
+	})
+}
+
+// testNotifierInFlight stops sending while its message is on the way.
+type testNotifierInFlight struct {
+	cancel context.CancelFunc
+}
+
+func (t testNotifierInFlight) Notify(ctx context.Context, gifter Participant, giftees []Participant, emailTemplate *Email) (string, error) {
+	t.cancel()
+	<-ctx.Done()
+	return "", fmt.Errorf("error posting message: %w", ctx.Err())
+}
+
+type testNotifierFunc func()
+
+func (f testNotifierFunc) Notify(ctx context.Context, gifter Participant, giftees []Participant, emailTemplate *Email) (string, error) {
+	f()
+	return "", nil
+}
+
+func TestSender_SendLogging(t *testing.T) {
+	names := []string{"Fred", "Wilma", "Barney", "Betty", "Pebbles"}
+	people := Participants{}
+	for _, name := range names {
+		people[name] = Participant{Name: name}
+	}
+	// Betty's text fails, so her assignment goes by email
+	people["Betty"] = Participant{Name: "Betty", Channels: []string{"sms", "email"}}
+	var buf bytes.Buffer
+	s := &Sender{
+		Notifier:          testNotifierNoError{},
+		Notifiers:         map[string]Notifier{"sms": testNotifierError{}, "email": testNotifierNoError{}},
+		ParticipantLoader: testParticipantsLoader(people),
+		EmailTemplate:     &Email{Subject: "Secret Santa", Body: template.Must(template.New("").Parse("{{.Gifter.Name}} buys for {{.GifteeNames}}"))},
+		Logger:            slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+	delivered := map[string]Delivery{}
+	s.Delivered = func(d Delivery, err error) {
+		if err != nil {
+			t.Errorf("Sender.Delivered() called with error %v for %s", err, d.Gifter)
+		}
+		delivered[d.Gifter] = d
+	}
+	if err := s.Send(context.Background(), ""); err != nil {
+		t.Fatalf("Sender.Send() error = %v", err)
+	}
+	if len(delivered) != len(names) || delivered["Betty"].Channel != "email" {
+		t.Errorf("Sender.Delivered() was called with %v", delivered)
+	}
+	dec := json.NewDecoder(&buf)
+	records := 0
+	for dec.More() {
+		var record map[string]any
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records++
+		gifter, _ := record["gifter"].(string)
+		delete(record, "gifter")
+		line := fmt.Sprint(record)
+		for _, name := range names {
+			if strings.Contains(line, name) {
+				t.Errorf("log record for %q mentions %s: %v", gifter, name, line)
+			}
+		}
+	}
+	if records < len(names) {
+		t.Errorf("logged %d records, want one for each delivery at least", records)
+	}
+}
+
+func TestSender_SendWithoutDefaultNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		people  Participants
+		wantErr string
+		want    []string
+	}{
+		{
+			name: "Everyone lists a channel",
+			people: Participants{
+				"Fred":  {Name: "Fred", Channels: []string{"sms"}},
+				"Wilma": {Name: "Wilma", Channels: []string{"sms"}},
+			},
+			want: []string{"Fred", "Wilma"},
+		},
+		{
+			name: "Someone lists no channel",
+			people: Participants{
+				"Fred":    {Name: "Fred", Channels: []string{"sms"}},
+				"Wilma":   {Name: "Wilma"},
+				"Pebbles": {Name: "Pebbles"},
+			},
+			wantErr: "Pebbles and Wilma list no channels",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var notified []string
+			s := &Sender{
+				Notifiers:         map[string]Notifier{"sms": testNotifierRecorder{notified: &notified}},
+				ParticipantLoader: testParticipantsLoader(tt.people),
+				EmailTemplate:     &Email{},
+			}
+			err := s.Send(context.Background(), "")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Sender.Send() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("Sender.Send() error = %v", err)
+			}
+			slices.Sort(notified)
+			if !slices.Equal(notified, tt.want) {
+				t.Errorf("notified %v, want %v", notified, tt.want)
+			}
+		})
+	}
+}
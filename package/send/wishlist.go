@@ -0,0 +1,122 @@
+package send
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// WishlistItem is a single gift idea. Only the name is required. Priority 1
+// is the most wanted; items without a priority are listed last.
+type WishlistItem struct {
+	Name     string
+	URL      string
+	Price    string
+	Priority int
+}
+
+func (w WishlistItem) String() string {
+	var details []string
+	if w.Price != "" {
+		details = append(details, w.Price)
+	}
+	if w.Priority > 0 {
+		details = append(details, fmt.Sprintf("priority %d", w.Priority))
+	}
+	s := w.Name
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	if w.URL != "" {
+		s += " " + w.URL
+	}
+	return s
+}
+
+// ParseWishlistItem reads an item written as "Name|URL|Price|Priority".
+// Trailing fields may be left out.
+func ParseWishlistItem(s string) (WishlistItem, error) {
+	fields := strings.Split(s, "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if len(fields) > 4 {
+		return WishlistItem{}, fmt.Errorf("wishlist item %q has more than 4 fields", s)
+	}
+	return NewWishlistItem(fields...)
+}
+
+// NewWishlistItem builds an item from its name, url, price and priority, in
+// that order, checking the url and priority.
+func NewWishlistItem(fields ...string) (WishlistItem, error) {
+	item := WishlistItem{}
+	for len(fields) < 4 {
+		fields = append(fields, "")
+	}
+	item.Name = fields[0]
+	if item.Name == "" {
+		return item, fmt.Errorf("wishlist item has no name")
+	}
+	if fields[1] != "" {
+		u, err := url.Parse(fields[1])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return item, fmt.Errorf("wishlist item %q has an invalid link %q", item.Name, fields[1])
+		}
+		item.URL = fields[1]
+	}
+	item.Price = fields[2]
+	if fields[3] != "" {
+		priority, err := strconv.Atoi(fields[3])
+		if err != nil || priority < 1 {
+			return item, fmt.Errorf("wishlist item %q has an invalid priority %q", item.Name, fields[3])
+		}
+		item.Priority = priority
+	}
+	return item, nil
+}
+
+// FormatWishlist writes items in the form read by ParseWishlist.
+func FormatWishlist(items []WishlistItem) string {
+	formatted := make([]string, 0, len(items))
+	for _, item := range items {
+		fields := []string{item.Name, item.URL, item.Price, ""}
+		if item.Priority > 0 {
+			fields[3] = strconv.Itoa(item.Priority)
+		}
+		formatted = append(formatted, strings.TrimRight(strings.Join(fields, "|"), "|"))
+	}
+	return strings.Join(formatted, "; ")
+}
+
+// ParseWishlist reads a semicolon separated list of items, see
+// ParseWishlistItem.
+func ParseWishlist(s string) ([]WishlistItem, error) {
+	var items []WishlistItem
+	for _, raw := range SplitList(s, ";") {
+		item, err := ParseWishlistItem(raw)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	SortWishlist(items)
+	return items, nil
+}
+
+// SortWishlist orders items by priority, keeping unprioritised items last
+// and in their original order.
+func SortWishlist(items []WishlistItem) {
+	slices.SortStableFunc(items, func(a, b WishlistItem) int {
+		switch {
+		case a.Priority == b.Priority:
+			return 0
+		case a.Priority == 0:
+			return 1
+		case b.Priority == 0:
+			return -1
+		}
+		return a.Priority - b.Priority
+	})
+}
@@ -0,0 +1,63 @@
+package send
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWishlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []WishlistItem
+		wantErr bool
+	}{
+		{
+			name:  "Empty string returns no items",
+			input: "",
+			want:  nil,
+		},
+		{
+			name:  "Items are trimmed and sorted by priority",
+			input: " Socks ; Book|https://example.com/book|$15|2;Lego||$40|1",
+			want: []WishlistItem{
+				{Name: "Lego", Price: "$40", Priority: 1},
+				{Name: "Book", URL: "https://example.com/book", Price: "$15", Priority: 2},
+				{Name: "Socks"},
+			},
+		},
+		{
+			name:    "Invalid link returns an error",
+			input:   "Book|not a link",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid priority returns an error",
+			input:   "Book|||first",
+			wantErr: true,
+		},
+		{
+			name:    "Missing name returns an error",
+			input:   "|https://example.com",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWishlist(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseWishlist() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWishlist() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr {
+				again, _ := ParseWishlist(FormatWishlist(got))
+				if !reflect.DeepEqual(again, got) {
+					t.Errorf("FormatWishlist() did not round trip, got %v", again)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/reveal"
+	"github.com/dcmcand/go-secret-santa/package/send"
+	"github.com/dcmcand/go-secret-santa/package/store"
+)
+
+type adminData struct {
+	Token         string
+	InviteLink    string
+	Open          bool
+	Registrations []store.Registration
+	Drawing       bool
+	Drawn         bool
+	DrawError     string
+	// Problems would stop the draw working or someone getting their
+	// assignment.
+	Problems []string
+	Draw     *send.Draw
+	People   []personStatus
+	Sent     int
+	// CanResend and CanDrop say which actions the server was given.
+	CanResend bool
+	CanDrop   bool
+	// Revealed is when the organiser last looked at the assignments, and
+	// Assignments holds them only on the page shown when they do.
+	Revealed    time.Time
+	Assignments []assignment
+}
+
+// personStatus is one row of the organiser's page. It never says who
+// anyone is buying for.
+type personStatus struct {
+	store.Registration
+	InDraw bool
+	// Delivery is their last delivery, if there was one.
+	Delivery *send.Delivery
+	// Outdated is set when their assignment changed after it was sent.
+	Outdated bool
+	// Views are when they opened their reveal link.
+	Views []time.Time
+	// Reveal is set when they were sent a reveal link.
+	Reveal bool
+}
+
+// LastView is when they last opened their reveal link.
+func (p personStatus) LastView() time.Time {
+	if len(p.Views) == 0 {
+		return time.Time{}
+	}
+	return p.Views[len(p.Views)-1]
+}
+
+type assignment struct {
+	Gifter  string
+	Giftees string
+}
+
+func (s *Server) adminPage(w http.ResponseWriter, r *http.Request) {
+	data, err := s.adminData()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	render(w, http.StatusOK, adminPage, data)
+}
+
+func (s *Server) adminData() (adminData, error) {
+	s.mu.Lock()
+	data := adminData{
+		Token:         s.AdminToken,
+		InviteLink:    strings.TrimSuffix(s.BaseURL, "/") + "/join/" + s.Store.InviteToken(),
+		Open:          s.Store.IsOpen(),
+		Registrations: s.Store.Registrations(),
+		Drawing:       s.drawing,
+		Drawn:         s.drawn,
+		CanResend:     s.Resend != nil,
+		CanDrop:       s.Drop != nil,
+		Revealed:      s.Store.AssignmentsShown(),
+	}
+	if s.drawErr != nil {
+		data.DrawError = s.drawErr.Error()
+	}
+	s.mu.Unlock()
+
+	var deliveries []send.Delivery
+	if s.Status != nil {
+		var err error
+		if data.Draw, deliveries, err = s.Status(); err != nil {
+			return data, err
+		}
+	}
+	// A draw that has been sent to anyone survives a restart
+	if data.Draw != nil && len(deliveries) > 0 && !data.Drawing {
+		data.Drawn = true
+	}
+	data.Problems = problems(data.Registrations, data.Draw)
+
+	views := map[string]reveal.Assignment{}
+	if s.Reveals != nil && data.Draw != nil {
+		assignments, err := s.Reveals.Assignments()
+		if err != nil {
+			return data, err
+		}
+		for _, a := range assignments {
+			if a.Draw == data.Draw.ID {
+				views[a.Gifter] = a
+			}
+		}
+	}
+	byGifter := map[string]send.Delivery{}
+	for _, d := range deliveries {
+		byGifter[d.Gifter] = d
+	}
+	for _, reg := range data.Registrations {
+		p := personStatus{Registration: reg}
+		if data.Draw != nil {
+			_, p.InDraw = data.Draw.Assignment.Giftees(reg.Name)
+		}
+		if d, ok := byGifter[reg.Name]; ok && p.InDraw {
+			p.Delivery = &d
+			p.Outdated = d.Revision < data.Draw.Revisions[reg.Name]
+			if !p.Outdated {
+				data.Sent++
+			}
+		}
+		if a, ok := views[reg.Name]; ok {
+			p.Reveal, p.Views = true, a.Views
+		}
+		data.People = append(data.People, p)
+	}
+	return data, nil
+}
+
+// problems lists what would stop the draw working or someone getting their
+// assignment, in the order people signed up.
+func problems(regs []store.Registration, draw *send.Draw) []string {
+	var found []string
+	if len(regs) < 2 {
+		found = append(found, "At least two people need to sign up before names can be drawn.")
+	}
+	names := make(map[string]bool, len(regs))
+	for _, r := range regs {
+		names[r.Name] = true
+	}
+	for _, r := range regs {
+		if addr, err := mail.ParseAddress(r.Email); err != nil || addr.Address != r.Email {
+			found = append(found, fmt.Sprintf("%s's email, %s, doesn't look right.", r.Name, r.Email))
+		}
+		excluded := 0
+		for _, name := range r.Exclusions {
+			if !names[name] {
+				found = append(found, fmt.Sprintf("%s asked not to buy for %s, but nobody by that name has signed up.", r.Name, name))
+			} else if name != r.Name {
+				excluded++
+			}
+		}
+		if len(regs) > 1 && excluded >= len(regs)-1 {
+			found = append(found, fmt.Sprintf("%s has asked not to buy for anyone who has signed up.", r.Name))
+		}
+		if draw != nil {
+			if _, ok := draw.Assignment.Giftees(r.Name); !ok {
+				found = append(found, fmt.Sprintf("%s signed up after names were drawn, so isn't in the draw.", r.Name))
+			}
+		}
+	}
+	if draw != nil {
+		// Pairs are in gifter order, so these are too
+		for _, pair := range draw.Assignment.Pairs {
+			if !names[pair.Gifter] {
+				found = append(found, fmt.Sprintf("%s is in the draw but their sign up is gone, so nobody can be sent their assignment until they're dropped.", pair.Gifter))
+			}
+		}
+	}
+	return found
+}
+
+func (s *Server) closeRegistration(w http.ResponseWriter, r *http.Request) {
+	if err := s.Store.CloseRegistration(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/"+s.AdminToken, http.StatusSeeOther)
+}
+
+func (s *Server) startDraw(w http.ResponseWriter, r *http.Request) {
+	if s.Store.IsOpen() {
+		http.Error(w, "close registration before drawing names", http.StatusConflict)
+		return
+	}
+	if len(s.Store.Registrations()) < 2 {
+		http.Error(w, "at least two people need to sign up before drawing names", http.StatusConflict)
+		return
+	}
+	s.mu.Lock()
+	if s.drawing || s.drawn {
+		s.mu.Unlock()
+		http.Redirect(w, r, "/admin/"+s.AdminToken, http.StatusSeeOther)
+		return
+	}
+	s.drawing, s.drawErr = true, nil
+	s.done = make(chan struct{})
+	s.mu.Unlock()
+
+	go func() {
+		err := s.Draw(s.context())
+		if err != nil {
+			slog.Error("error drawing names", "err", err)
+		} else {
+			slog.Info("names drawn and sent")
+		}
+		s.mu.Lock()
+		s.drawing, s.drawn, s.drawErr = false, err == nil, err
+		close(s.done)
+		s.mu.Unlock()
+	}()
+	http.Redirect(w, r, "/admin/"+s.AdminToken, http.StatusSeeOther)
+}
+
+// context is the server's, so sends started from a page carry on if the
+// organiser closes it.
+func (s *Server) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// errDrawing is returned for changes made while names are being drawn.
+var errDrawing = errors.New("wait for the draw to finish first")
+
+func (s *Server) isDrawing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.drawing
+}
+
+// isDrawn reports whether there is a saved draw.
+func (s *Server) isDrawn() (bool, error) {
+	if s.Status == nil {
+		return false, nil
+	}
+	draw, _, err := s.Status()
+	return draw != nil, err
+}
+
+// isSent reports whether there is a saved draw that has been sent to
+// anyone, so changes to it have to be repaired rather than drawn again.
+func (s *Server) isSent() (bool, error) {
+	if s.Status == nil {
+		return false, nil
+	}
+	draw, deliveries, err := s.Status()
+	return draw != nil && len(deliveries) > 0, err
+}
+
+func (s *Server) resend(w http.ResponseWriter, r *http.Request) {
+	if s.isDrawing() {
+		http.Error(w, errDrawing.Error(), http.StatusConflict)
+		return
+	}
+	if err := s.Resend(s.context(), r.PostFormValue("name")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/"+s.AdminToken, http.StatusSeeOther)
+}
+
+// fixEmail corrects someone's email, and sends them their assignment again
+// if it has already gone to the wrong address.
+func (s *Server) fixEmail(w http.ResponseWriter, r *http.Request) {
+	if s.isDrawing() {
+		http.Error(w, errDrawing.Error(), http.StatusConflict)
+		return
+	}
+	name, email := r.PostFormValue("name"), strings.TrimSpace(r.PostFormValue("email"))
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		http.Error(w, fmt.Sprintf("%q is not a valid email address", email), http.StatusBadRequest)
+		return
+	}
+	if err := s.Store.SetEmail(name, email); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sent, err := s.isSent()
+	if err == nil && sent && s.Resend != nil {
+		err = s.Resend(s.context(), name)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/"+s.AdminToken, http.StatusSeeOther)
+}
+
+// dropOut removes someone who can no longer take part. Before names are
+// drawn that only means removing their sign up.
+func (s *Server) dropOut(w http.ResponseWriter, r *http.Request) {
+	if s.isDrawing() {
+		http.Error(w, errDrawing.Error(), http.StatusConflict)
+		return
+	}
+	name := r.PostFormValue("name")
+	drawn, err := s.isDrawn()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// A saved draw still has them in it even if nothing has been sent
+	if drawn {
+		if s.Drop == nil {
+			http.Error(w, "names have been drawn, so nobody can drop out here", http.StatusConflict)
+			return
+		}
+		if err := s.Drop(s.context(), name); err != nil {
+			// Once the draw is saved without them their sign up goes too,
+			// even if telling the others about it failed
+			if draw, _, statusErr := s.Status(); statusErr == nil && draw != nil {
+				if _, ok := draw.Assignment.Giftees(name); !ok {
+					err = errors.Join(err, s.Store.Remove(name))
+				}
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := s.Store.Remove(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/admin/"+s.AdminToken, http.StatusSeeOther)
+}
+
+// showAssignments is the break glass: it shows who is buying for whom,
+// which spoils the surprise for the organiser, so it has to be confirmed
+// and the page says when it was last done.
+func (s *Server) showAssignments(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("confirm") != "show" {
+		http.Error(w, `type "show" to confirm you want to see every assignment`, http.StatusBadRequest)
+		return
+	}
+	draw, _, err := s.Status()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if draw == nil {
+		http.Error(w, "names haven't been drawn", http.StatusConflict)
+		return
+	}
+	if err := s.Store.ShowAssignments(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Warn("every assignment was shown on the organiser's page", "draw", draw.ID)
+	data, err := s.adminData()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, pair := range draw.Assignment.Pairs {
+		data.Assignments = append(data.Assignments, assignment{Gifter: pair.Gifter, Giftees: strings.Join(pair.Giftees, ", ")})
+	}
+	render(w, http.StatusOK, adminPage, data)
+}
@@ -0,0 +1,169 @@
+package server
+
+import "html/template"
+
+const layout = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Secret Santa</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+label { display: block; margin-top: 1em; font-weight: bold; }
+input, textarea { width: 100%; padding: .4em; box-sizing: border-box; font: inherit; }
+small { color: #555; }
+button { margin-top: 1em; padding: .5em 1.5em; font: inherit; }
+.error { color: #a00; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .3em .5em; border-bottom: 1px solid #ddd; }
+.assignment { white-space: pre-wrap; background: #f6f6f6; padding: 1em; }
+</style>
+</head>
+<body>
+<h1>Secret Santa</h1>
+{{template "content" .}}
+</body>
+</html>`
+
+func page(content string) *template.Template {
+	return template.Must(template.Must(template.New("layout").Parse(layout)).New("content").Parse(content)).Lookup("layout")
+}
+
+var signUpPage = page(`
+{{- if not .Open}}
+<p>Sorry, sign ups for this Secret Santa have closed.</p>
+{{- else if .Done}}
+<p>Thanks {{.Values.Name}}, you're signed up! You'll hear who you're buying for once names are drawn.</p>
+<p>Made a mistake? Ask the organiser to change your details.</p>
+{{- else}}
+<p>Sign up to take part. Your details are only shared with the person buying your gift.</p>
+{{- with .Error}}<p class="error">{{.}}</p>{{end}}
+<form method="post">
+<label for="name">Name</label>
+<input id="name" name="name" required value="{{.Values.Name}}">
+<label for="email">Email</label>
+<input id="email" name="email" type="email" required value="{{.Values.Email}}">
+<label for="interests">Interests</label>
+<input id="interests" name="interests" value="{{range $i, $v := .Values.Interests}}{{if $i}}, {{end}}{{$v}}{{end}}">
+<small>Separated by commas, e.g. bowling, jokes, movies</small>
+<label for="exclusions">People you shouldn't buy for</label>
+<input id="exclusions" name="exclusions" value="{{range $i, $v := .Values.Exclusions}}{{if $i}}, {{end}}{{$v}}{{end}}">
+<small>Their names as they signed up, separated by commas, e.g. your partner</small>
+<label for="address">Shipping address</label>
+<textarea id="address" name="address" rows="3">{{.Values.Address}}</textarea>
+<label for="country">Country</label>
+<input id="country" name="country" value="{{.Values.Country}}">
+<button type="submit">Sign up</button>
+</form>
+{{- end}}`)
+
+var adminPage = page(`
+<h2>{{len .Registrations}} signed up</h2>
+{{- if .Open}}
+<p>Registration is open. Send people this link to sign up:<br><a href="{{.InviteLink}}">{{.InviteLink}}</a></p>
+<form method="post" action="/admin/{{.Token}}/close"><button type="submit">Close registration</button></form>
+{{- else if .Drawing}}
+<p>Drawing names and sending assignments&hellip; refresh to see when it's done.</p>
+{{- else if .Drawn}}
+{{- with .Draw}}
+<p>Names were drawn on {{.Created.Format "Jan 2 15:04"}}. {{$.Sent}} of {{len .Assignment.Pairs}} people have been sent their current assignment.</p>
+{{- else}}
+<p>Names have been drawn and everyone has been sent their assignment.</p>
+{{- end}}
+{{- else}}
+<p>Registration is closed.</p>
+{{- with .DrawError}}<p class="error">The draw failed: {{.}}</p>{{end}}
+<form method="post" action="/admin/{{.Token}}/draw"><button type="submit">{{if .DrawError}}Try again{{else}}Draw names{{end}}</button></form>
+{{- end}}
+{{- with .Problems}}
+<h3>Problems</h3>
+<ul class="error">
+{{- range .}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .People}}
+<table>
+<tr><th>Name</th><th>Email</th><th>Signed up</th>{{if $.Draw}}<th>Assignment</th><th>Opened</th>{{end}}<th></th></tr>
+{{- range .}}
+<tr>
+<td>{{.Name}}</td>
+<td>{{.Email}}</td>
+<td>{{.Registered.Format "Jan 2 15:04"}}</td>
+{{- if $.Draw}}
+<td>{{if not .InDraw}}Not in the draw{{else if .Outdated}}Changed since it was sent{{else if .Delivery}}Sent{{with .Delivery.Channel}} by {{.}}{{end}} {{.Delivery.Time.Format "Jan 2 15:04"}}{{else}}Not sent{{end}}</td>
+<td>{{if .Reveal}}{{if .Views}}{{.LastView.Format "Jan 2 15:04"}}{{else}}Not yet{{end}}{{end}}</td>
+{{- end}}
+<td>
+{{- if and $.CanResend .InDraw $.Drawn}}
+<form method="post" action="/admin/{{$.Token}}/resend"><input type="hidden" name="name" value="{{.Name}}"><button type="submit">Resend</button></form>
+{{- end}}
+{{- if not $.Open}}
+<form method="post" action="/admin/{{$.Token}}/email"><input type="hidden" name="name" value="{{.Name}}"><input name="email" type="email" required value="{{.Email}}" aria-label="{{.Name}}'s email"><button type="submit">Fix email</button></form>
+{{- end}}
+{{- if or (not $.Drawn) $.CanDrop}}
+<form method="post" action="/admin/{{$.Token}}/drop"><input type="hidden" name="name" value="{{.Name}}"><button type="submit">{{if $.Drawn}}Drop out{{else}}Remove{{end}}</button></form>
+{{- end}}
+</td>
+</tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Drawn}}
+{{- with .Assignments}}
+<h3>Assignments</h3>
+<table>
+<tr><th>Gifter</th><th>Buys for</th></tr>
+{{- range .}}
+<tr><td>{{.Gifter}}</td><td>{{.Giftees}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<h3>Break glass</h3>
+<p>Who is buying for whom is never shown unless you need it, for example to sort out a problem with a gift. It can't be unseen.</p>
+<form method="post" action="/admin/{{.Token}}/assignments">
+<label for="confirm">Type "show" to see every assignment</label>
+<input id="confirm" name="confirm" required autocomplete="off">
+<button type="submit">Show assignments</button>
+</form>
+{{- end}}
+{{- if not .Revealed.IsZero}}<p><small>The assignments were last shown here at {{.Revealed.Format "Jan 2 15:04"}}.</small></p>{{end}}
+{{- end}}`)
+
+var revealPage = page(`
+{{- if .Error}}
+<p class="error">Sorry, {{.Error}}.</p>
+{{- else if .Revealed}}
+<h2>{{.Assignment.Subject}}</h2>
+<div class="assignment">{{.Assignment.Body}}</div>
+<p><small>{{with .Assignment.ViewsLeft}}This page can be opened {{.}} more time{{if gt . 1}}s{{end}}.{{else}}This was the last time this page can be opened, so make a note of your assignment.{{end}}</small></p>
+{{- else}}
+<p>Hi {{.Assignment.Gifter}}, make sure nobody is looking over your shoulder.</p>
+<form method="post"><button type="submit">Show my assignment</button></form>
+<p><small>Your assignment can be shown {{.Assignment.ViewsLeft}} more time{{if gt .Assignment.ViewsLeft 1}}s{{end}}.</small></p>
+{{- end}}`)
+
+var relayPage = page(`
+{{- if not .Side}}
+<p class="error">Sorry, {{.Error}}.</p>
+{{- else}}
+<h2>Messages with {{.With}}</h2>
+{{- if eq .Side "giftee"}}
+<p>Your Secret Santa can ask you questions here without you finding out who they are.</p>
+{{- else}}
+<p>Ask {{.Conversation.Giftee.Name}} anything here. They'll only ever see "your Secret Santa".</p>
+{{- end}}
+{{- range .Conversation.Messages}}
+<p><strong>{{$.From .}}</strong> <small>{{.Sent.Format "Jan 2 15:04"}}</small></p>
+<div class="assignment">{{.Text}}</div>
+{{- end}}
+{{- if .Sent}}<p>Your message has been sent.</p>{{end}}
+{{- with .Error}}<p class="error">{{.}}</p>{{end}}
+<form method="post">
+<label for="message">Message</label>
+<textarea id="message" name="message" rows="5" maxlength="{{.MaxLength}}" required>{{.Text}}</textarea>
+<button type="submit">Send</button>
+</form>
+{{- end}}`)
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/relay"
+	"github.com/dcmcand/go-secret-santa/package/reveal"
+	"github.com/dcmcand/go-secret-santa/package/send"
+	"github.com/dcmcand/go-secret-santa/package/store"
+)
+
+// Server lets participants sign up through an invite link and lets the
+// organiser close registration, start the draw and follow up on who has
+// been sent their assignment.
+//
+// Participants sign up at /join/<invite token>. The organiser's page is
+// /admin/<admin token>, so both links should be kept to the people they are
+// meant for. With Reveals set, gifters see their assignment at the link
+// they were sent, /reveal/<token>. With Relay set, gifters and giftees
+// message each other at /relay/<token> without the giftee learning who is
+// buying for them.
+type Server struct {
+	Store *store.Store
+	// AdminToken is part of the organiser's link.
+	AdminToken string
+	// BaseURL is where the server is reached, e.g. https://santa.example.com,
+	// and is used to show the invite link.
+	BaseURL string
+	// Draw draws names from the store and sends everyone their assignment.
+	// It runs in the background after the organiser starts it.
+	Draw func(ctx context.Context) error
+	// Reveals holds assignments sent as links. Nil turns the reveal page
+	// off.
+	Reveals *reveal.Store
+	// Status returns the saved draw and who has been sent their assignment
+	// from it, or a nil draw before names are drawn.
+	Status func() (*send.Draw, []send.Delivery, error)
+	// Resend sends gifters their assignment from the saved draw again.
+	Resend func(ctx context.Context, gifters ...string) error
+	// Drop takes someone out of the saved draw, gives the people buying for
+	// them new giftees and sends those people their new assignment, or
+	// forgets the draw if nothing from it has been sent. Their sign up is
+	// removed once the draw is saved without them, even if sending the new
+	// assignments fails.
+	Drop func(ctx context.Context, name string) error
+	// Relay passes messages between gifters and giftees. Nil turns the
+	// relay page off.
+	Relay *relay.Relay
+
+	ctx  context.Context
+	once sync.Once
+	mux  *http.ServeMux
+
+	mu      sync.Mutex
+	drawing bool
+	drawn   bool
+	drawErr error
+	done    chan struct{}
+}
+
+// ListenAndServe serves on addr until ctx is done. A draw in progress is
+// cancelled along with ctx.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	s.ctx = ctx
+	srv := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() { errs <- srv.ListenAndServe() }()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdown)
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.once.Do(s.routes)
+	// Pages hold tokens and personal details, so keep them out of caches
+	// and other sites' logs
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /join/{token}", s.invited(s.signUpForm))
+	s.mux.HandleFunc("POST /join/{token}", s.invited(s.signUp))
+	s.mux.HandleFunc("GET /admin/{token}", s.admin(s.adminPage))
+	s.mux.HandleFunc("POST /admin/{token}/close", s.admin(s.closeRegistration))
+	s.mux.HandleFunc("POST /admin/{token}/draw", s.admin(s.startDraw))
+	s.mux.HandleFunc("POST /admin/{token}/email", s.admin(s.fixEmail))
+	s.mux.HandleFunc("POST /admin/{token}/drop", s.admin(s.dropOut))
+	if s.Status != nil {
+		s.mux.HandleFunc("POST /admin/{token}/assignments", s.admin(s.showAssignments))
+	}
+	if s.Resend != nil {
+		s.mux.HandleFunc("POST /admin/{token}/resend", s.admin(s.resend))
+	}
+	if s.Reveals != nil {
+		s.mux.HandleFunc("GET /reveal/{token}", s.revealForm)
+		s.mux.HandleFunc("POST /reveal/{token}", s.reveal)
+	}
+	if s.Relay != nil {
+		s.mux.HandleFunc("GET /relay/{token}", s.conversation)
+		s.mux.HandleFunc("POST /relay/{token}", s.relayMessage)
+	}
+}
+
+// invited only lets through requests with the invite token.
+func (s *Server) invited(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tokenMatches(r.PathValue("token"), s.Store.InviteToken()) {
+			http.NotFound(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// admin only lets through requests with the admin token.
+func (s *Server) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.AdminToken == "" || !tokenMatches(r.PathValue("token"), s.AdminToken) {
+			http.NotFound(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func tokenMatches(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+type signUpData struct {
+	Open   bool
+	Error  string
+	Done   bool
+	Values store.Registration
+}
+
+func (s *Server) signUpForm(w http.ResponseWriter, r *http.Request) {
+	render(w, http.StatusOK, signUpPage, signUpData{Open: s.Store.IsOpen()})
+}
+
+func (s *Server) signUp(w http.ResponseWriter, r *http.Request) {
+	reg := store.Registration{
+		Name:       r.PostFormValue("name"),
+		Email:      r.PostFormValue("email"),
+		Interests:  send.SplitList(r.PostFormValue("interests"), ","),
+		Exclusions: send.SplitList(r.PostFormValue("exclusions"), ","),
+		Address:    strings.TrimSpace(r.PostFormValue("address")),
+		Country:    strings.TrimSpace(r.PostFormValue("country")),
+	}
+	err := s.Store.Register(reg)
+	switch {
+	case errors.Is(err, store.ErrClosed):
+		render(w, http.StatusForbidden, signUpPage, signUpData{})
+	case err != nil:
+		render(w, http.StatusBadRequest, signUpPage, signUpData{Open: true, Error: err.Error(), Values: reg})
+	default:
+		render(w, http.StatusOK, signUpPage, signUpData{Open: true, Done: true, Values: reg})
+	}
+}
+
+type revealData struct {
+	Error      string
+	Assignment reveal.Assignment
+	Revealed   bool
+}
+
+// revealForm asks the gifter to press a button before their assignment is
+// shown, so link previews and mail scanners that open links don't use up
+// their views.
+func (s *Server) revealForm(w http.ResponseWriter, r *http.Request) {
+	a, err := s.Reveals.Peek(r.PathValue("token"))
+	if err != nil {
+		s.revealError(w, err)
+		return
+	}
+	render(w, http.StatusOK, revealPage, revealData{Assignment: a})
+}
+
+func (s *Server) reveal(w http.ResponseWriter, r *http.Request) {
+	a, err := s.Reveals.View(r.PathValue("token"))
+	if err != nil {
+		s.revealError(w, err)
+		return
+	}
+	render(w, http.StatusOK, revealPage, revealData{Assignment: a, Revealed: true})
+}
+
+func (s *Server) revealError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, reveal.ErrInvalid):
+		render(w, http.StatusNotFound, revealPage, revealData{Error: err.Error()})
+	case errors.Is(err, reveal.ErrExpired), errors.Is(err, reveal.ErrUsedUp):
+		render(w, http.StatusGone, revealPage, revealData{Error: err.Error() + ", ask the organiser to send it again"})
+	default:
+		render(w, http.StatusInternalServerError, revealPage, revealData{Error: err.Error()})
+	}
+}
+
+type relayData struct {
+	Error        string
+	Side         string
+	Conversation relay.Conversation
+	Text         string
+	Sent         bool
+	MaxLength    int
+}
+
+// With returns who a message is exchanged with, as the side viewing the
+// conversation knows them.
+func (d relayData) With() string {
+	if d.Side == relay.Giftee {
+		return "your Secret Santa"
+	}
+	return d.Conversation.Giftee.Name
+}
+
+// From labels a message in the conversation.
+func (d relayData) From(m relay.Message) string {
+	if m.From == d.Side {
+		return "You"
+	}
+	if m.From == relay.Gifter {
+		return "Your Secret Santa"
+	}
+	return d.Conversation.Giftee.Name
+}
+
+func (s *Server) conversation(w http.ResponseWriter, r *http.Request) {
+	c, side, err := s.Relay.Lookup(r.PathValue("token"))
+	if err != nil {
+		s.relayError(w, err)
+		return
+	}
+	render(w, http.StatusOK, relayPage, relayData{Side: side, Conversation: c, MaxLength: relay.MaxMessageLength})
+}
+
+func (s *Server) relayMessage(w http.ResponseWriter, r *http.Request) {
+	token, text := r.PathValue("token"), r.PostFormValue("message")
+	c, side, err := s.Relay.Lookup(token)
+	if err != nil {
+		s.relayError(w, err)
+		return
+	}
+	sent, err := s.Relay.Send(r.Context(), token, text)
+	if errors.Is(err, relay.ErrEmpty) || errors.Is(err, relay.ErrTooLong) {
+		render(w, http.StatusBadRequest, relayPage, relayData{Error: err.Error(), Side: side, Conversation: c, Text: text, MaxLength: relay.MaxMessageLength})
+		return
+	}
+	if errors.Is(err, relay.ErrInvalid) || errors.Is(err, relay.ErrClosed) {
+		s.relayError(w, err)
+		return
+	}
+	if err != nil {
+		// The error names who the message was for, which a giftee mustn't
+		// see, so it only goes to the log
+		slog.Error("a relayed message couldn't be delivered", "err", err)
+		render(w, http.StatusInternalServerError, relayPage, relayData{Error: "your message couldn't be delivered, try again later", Side: side, Conversation: c, Text: text, MaxLength: relay.MaxMessageLength})
+		return
+	}
+	render(w, http.StatusOK, relayPage, relayData{Side: side, Conversation: sent, Sent: true, MaxLength: relay.MaxMessageLength})
+}
+
+func (s *Server) relayError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, relay.ErrInvalid):
+		render(w, http.StatusNotFound, relayPage, relayData{Error: err.Error()})
+	case errors.Is(err, relay.ErrClosed):
+		render(w, http.StatusGone, relayPage, relayData{Error: err.Error()})
+	default:
+		slog.Error("error opening conversation", "err", err)
+		render(w, http.StatusInternalServerError, relayPage, relayData{Error: "something went wrong, try again later"})
+	}
+}
+
+func render(w http.ResponseWriter, status int, tmpl *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		fmt.Fprintf(w, "error rendering page: %v", template.HTMLEscapeString(err.Error()))
+	}
+}
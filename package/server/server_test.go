@@ -0,0 +1,383 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/relay"
+	"github.com/dcmcand/go-secret-santa/package/reveal"
+	"github.com/dcmcand/go-secret-santa/package/send"
+	"github.com/dcmcand/go-secret-santa/package/store"
+	"github.com/dcmcand/go-secret-santa/package/template"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server, chan struct{}) {
+	t.Helper()
+	st, err := store.Open(filepath.Join(t.TempDir(), "signups.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	drawn := make(chan struct{})
+	s := &Server{
+		Store:      st,
+		AdminToken: "admin",
+		Draw: func(ctx context.Context) error {
+			close(drawn)
+			return nil
+		},
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts, drawn
+}
+
+// noRedirects lets tests check where a form sends the browser.
+var noRedirects = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+func request(t *testing.T, method, u string, form url.Values) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := noRedirects.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestServer_SignUp(t *testing.T) {
+	s, ts, _ := newTestServer(t)
+	join := ts.URL + "/join/" + s.Store.InviteToken()
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		form       url.Values
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "Form", method: http.MethodGet, url: join, wantStatus: http.StatusOK, wantBody: "<form"},
+		{name: "Wrong invite", method: http.MethodGet, url: ts.URL + "/join/guess", wantStatus: http.StatusNotFound},
+		{
+			name:       "Sign up",
+			method:     http.MethodPost,
+			url:        join,
+			form:       url.Values{"name": {"Fred"}, "email": {"fred@bedrock.org"}, "interests": {"bowling, golf"}, "exclusions": {"Wilma"}},
+			wantStatus: http.StatusOK,
+			wantBody:   "Thanks Fred",
+		},
+		{
+			name:       "Missing email shows the form again",
+			method:     http.MethodPost,
+			url:        join,
+			form:       url.Values{"name": {"<Barney>"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `value="&lt;Barney&gt;"`,
+		},
+		{
+			name:       "Bad email shows the form again",
+			method:     http.MethodPost,
+			url:        join,
+			form:       url.Values{"name": {"Barney"}, "email": {"barney at bedrock"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "is not a valid email address",
+		},
+		{name: "Admin page needs its token", method: http.MethodGet, url: ts.URL + "/admin/guess", wantStatus: http.StatusNotFound},
+		{name: "Admin page", method: http.MethodGet, url: ts.URL + "/admin/admin", wantStatus: http.StatusOK, wantBody: "1 signed up"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := request(t, tt.method, tt.url, tt.form)
+			if status != tt.wantStatus {
+				t.Errorf("%s %s status = %d, want %d", tt.method, tt.url, status, tt.wantStatus)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("%s %s body doesn't contain %q:\n%s", tt.method, tt.url, tt.wantBody, body)
+			}
+		})
+	}
+
+	fred := s.Store.Registrations()[0]
+	if strings.Join(fred.Interests, "|") != "bowling|golf" || strings.Join(fred.Exclusions, "|") != "Wilma" {
+		t.Errorf("stored %+v", fred)
+	}
+}
+
+func TestServer_Draw(t *testing.T) {
+	s, ts, drawn := newTestServer(t)
+	join := ts.URL + "/join/" + s.Store.InviteToken()
+	for _, name := range []string{"Fred", "Wilma"} {
+		request(t, http.MethodPost, join, url.Values{"name": {name}, "email": {strings.ToLower(name) + "@bedrock.org"}})
+	}
+
+	if status, _ := request(t, http.MethodPost, ts.URL+"/admin/admin/draw", nil); status != http.StatusConflict {
+		t.Errorf("drawing while registration is open status = %d, want %d", status, http.StatusConflict)
+	}
+	if status, _ := request(t, http.MethodPost, ts.URL+"/admin/admin/close", nil); status != http.StatusSeeOther {
+		t.Errorf("closing registration status = %d, want %d", status, http.StatusSeeOther)
+	}
+	if status, body := request(t, http.MethodPost, join, url.Values{"name": {"Barney"}, "email": {"barney@bedrock.org"}}); status != http.StatusForbidden || !strings.Contains(body, "closed") {
+		t.Errorf("signing up after closing status = %d, want %d", status, http.StatusForbidden)
+	}
+	if status, _ := request(t, http.MethodPost, ts.URL+"/admin/admin/draw", nil); status != http.StatusSeeOther {
+		t.Errorf("drawing status = %d, want %d", status, http.StatusSeeOther)
+	}
+	<-drawn
+	<-s.done
+	if _, body := request(t, http.MethodGet, ts.URL+"/admin/admin", nil); !strings.Contains(body, "Names have been drawn") {
+		t.Errorf("admin page after the draw:\n%s", body)
+	}
+}
+
+func TestServer_Reveal(t *testing.T) {
+	s, ts, _ := newTestServer(t)
+	reveals, err := reveal.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Reveals = reveals
+	token, err := reveals.Add(reveal.Assignment{Gifter: "Fred", Subject: "Secret Santa", Body: "You are buying for <Barney>", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := ts.URL + "/reveal/" + token
+
+	// Opening the link doesn't reveal anything until the button is pressed
+	if status, body := request(t, http.MethodGet, link, nil); status != http.StatusOK || strings.Contains(body, "Barney") || !strings.Contains(body, "Show my assignment") {
+		t.Errorf("GET %s = %d:\n%s", link, status, body)
+	}
+	if status, body := request(t, http.MethodPost, link, nil); status != http.StatusOK || !strings.Contains(body, "You are buying for &lt;Barney&gt;") {
+		t.Errorf("POST %s = %d:\n%s", link, status, body)
+	}
+	if status, _ := request(t, http.MethodPost, link, nil); status != http.StatusGone {
+		t.Errorf("POST %s after its only view status = %d, want %d", link, status, http.StatusGone)
+	}
+	if status, _ := request(t, http.MethodGet, ts.URL+"/reveal/nope", nil); status != http.StatusNotFound {
+		t.Errorf("GET an invalid link status = %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestServer_Relay(t *testing.T) {
+	s, ts, _ := newTestServer(t)
+	r, err := relay.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Template, err = template.GetDefaultRelayTemplate("A message", "Santa Claus", "santa@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	sent := map[string]string{}
+	r.BaseURL = ts.URL
+	r.Deliver = func(ctx context.Context, to send.Participant, message *send.Email) (string, error) {
+		body, err := message.Render(to)
+		sent[to.Name] = body
+		return "id", err
+	}
+	s.Relay = r
+	link, err := r.Start("draw", send.Participant{Name: "Fred"}, send.Participant{Name: "Barney"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status, body := request(t, http.MethodGet, link, nil); status != http.StatusOK || !strings.Contains(body, "Messages with Barney") {
+		t.Errorf("GET %s = %d:\n%s", link, status, body)
+	}
+	if status, _ := request(t, http.MethodPost, link, url.Values{"message": {""}}); status != http.StatusBadRequest {
+		t.Errorf("POST an empty message status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status, body := request(t, http.MethodPost, link, url.Values{"message": {"What size are you?"}}); status != http.StatusOK || !strings.Contains(body, "Your message has been sent") {
+		t.Errorf("POST %s = %d:\n%s", link, status, body)
+	}
+	msg := sent["Barney"]
+	if !strings.Contains(msg, "What size are you?") || strings.Contains(msg, "Fred") {
+		t.Fatalf("Barney was sent %q", msg)
+	}
+	_, reply, ok := strings.Cut(msg, "Reply here: ")
+	if !ok {
+		t.Fatalf("no reply link in %q", msg)
+	}
+	reply, _, _ = strings.Cut(reply, "\n")
+
+	// The giftee sees the thread without their Secret Santa's name
+	if status, body := request(t, http.MethodGet, reply, nil); status != http.StatusOK || !strings.Contains(body, "Messages with your Secret Santa") || strings.Contains(body, "Fred") {
+		t.Errorf("GET %s = %d:\n%s", reply, status, body)
+	}
+	if status, _ := request(t, http.MethodPost, reply, url.Values{"message": {"Large"}}); status != http.StatusOK || !strings.Contains(sent["Fred"], "Large") {
+		t.Errorf("POST %s status = %d, Fred was sent %q", reply, status, sent["Fred"])
+	}
+
+	if status, _ := request(t, http.MethodGet, ts.URL+"/relay/nope", nil); status != http.StatusNotFound {
+		t.Errorf("GET an invalid link status = %d, want %d", status, http.StatusNotFound)
+	}
+	if err := r.Close("draw", "Fred", "Barney"); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := request(t, http.MethodGet, link, nil); status != http.StatusGone {
+		t.Errorf("GET a closed conversation status = %d, want %d", status, http.StatusGone)
+	}
+}
+
+// failing is a notifier that can't deliver anything.
+type failing struct{}
+
+func (failing) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	return "", errors.New("mail.bedrock.org refused " + gifter.Email)
+}
+
+func TestServer_RelayFailure(t *testing.T) {
+	s, ts, _ := newTestServer(t)
+	r, err := relay.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Template, err = template.GetDefaultRelayTemplate("A message", "Santa Claus", "santa@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var reply string
+	r.BaseURL = ts.URL
+	r.Deliver = func(ctx context.Context, to send.Participant, message *send.Email) (string, error) {
+		if to.Name == "Fred" {
+			// Delivered the way assignments are, so the error is the one
+			// the sender really returns
+			return (&send.Sender{Notifier: failing{}}).Message(ctx, to, message)
+		}
+		body, err := message.Render(to)
+		_, reply, _ = strings.Cut(body, "Reply here: ")
+		reply, _, _ = strings.Cut(reply, "\n")
+		return "id", err
+	}
+	s.Relay = r
+	link, err := r.Start("draw", send.Participant{Name: "Fred", Email: "fred@bedrock.org"}, send.Participant{Name: "Barney"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := request(t, http.MethodPost, link, url.Values{"message": {"What size are you?"}}); status != http.StatusOK || reply == "" {
+		t.Fatalf("POST %s status = %d, reply link %q", link, status, reply)
+	}
+
+	status, body := request(t, http.MethodPost, reply, url.Values{"message": {"Large"}})
+	if status != http.StatusInternalServerError || !strings.Contains(body, "couldn&#39;t be delivered") || !strings.Contains(body, "Large") {
+		t.Errorf("POST %s = %d, want the message kept and a generic error:\n%s", reply, status, body)
+	}
+	if strings.Contains(body, "Fred") || strings.Contains(body, "bedrock.org") {
+		t.Errorf("the giftee's page gives their Secret Santa away:\n%s", body)
+	}
+}
+
+func TestServer_Dashboard(t *testing.T) {
+	s, ts, _ := newTestServer(t)
+	for _, r := range []store.Registration{
+		{Name: "Fred", Email: "fred@bedrock.org"},
+		{Name: "Wilma", Email: "wilma@bedrock.org"},
+		{Name: "Barney", Email: "barney@bedrock.org", Exclusions: []string{"Dino"}},
+	} {
+		if err := s.Store.Register(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Store.CloseRegistration(); err != nil {
+		t.Fatal(err)
+	}
+	draw := &send.Draw{ID: "a", Created: time.Now(), Assignment: send.Assignment{Draw: "a", GiftsPerPerson: 1, Pairs: []send.Pair{
+		{Gifter: "Barney", Giftees: []string{"Fred"}}, {Gifter: "Fred", Giftees: []string{"Wilma"}}, {Gifter: "Wilma", Giftees: []string{"Barney"}},
+	}}}
+	deliveries := []send.Delivery{{Draw: "a", Gifter: "Fred", Channel: "email", Time: time.Now()}, {Draw: "a", Gifter: "Wilma", Time: time.Now()}}
+	var resent, dropped []string
+	s.Status = func() (*send.Draw, []send.Delivery, error) { return draw, deliveries, nil }
+	s.Resend = func(ctx context.Context, gifters ...string) error {
+		resent = append(resent, gifters...)
+		return nil
+	}
+	s.Drop = func(ctx context.Context, name string) error {
+		dropped = append(dropped, name)
+		return nil
+	}
+	admin := ts.URL + "/admin/admin"
+
+	status, body := request(t, http.MethodGet, admin, nil)
+	for _, want := range []string{"2 of 3 people have been sent", "Barney asked not to buy for Dino", "Sent by email", "Not sent", "Break glass"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard doesn't contain %q:\n%s", want, body)
+		}
+	}
+	if status != http.StatusOK || strings.Contains(body, "Buys for") {
+		t.Errorf("dashboard = %d, and must not show assignments:\n%s", status, body)
+	}
+
+	if status, _ := request(t, http.MethodPost, admin+"/resend", url.Values{"name": {"Barney"}}); status != http.StatusSeeOther || !slices.Equal(resent, []string{"Barney"}) {
+		t.Errorf("resend status = %d, resent %v", status, resent)
+	}
+	if status, _ := request(t, http.MethodPost, admin+"/email", url.Values{"name": {"Fred"}, "email": {"fred@"}}); status != http.StatusBadRequest {
+		t.Errorf("fixing an email with an invalid one status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status, _ := request(t, http.MethodPost, admin+"/email", url.Values{"name": {"Fred"}, "email": {"fred@slate.com"}}); status != http.StatusSeeOther || !slices.Equal(resent, []string{"Barney", "Fred"}) {
+		t.Errorf("fixing an email status = %d, resent %v", status, resent)
+	}
+	if got := s.Store.Registrations()[0].Email; got != "fred@slate.com" {
+		t.Errorf("Fred's email is %s after fixing it", got)
+	}
+	if status, _ := request(t, http.MethodPost, admin+"/drop", url.Values{"name": {"Wilma"}}); status != http.StatusSeeOther || !slices.Equal(dropped, []string{"Wilma"}) {
+		t.Errorf("dropping out status = %d, dropped %v", status, dropped)
+	}
+	if got := len(s.Store.Registrations()); got != 2 {
+		t.Errorf("%d sign ups after dropping one, want 2", got)
+	}
+
+	if status, _ := request(t, http.MethodPost, admin+"/assignments", url.Values{"confirm": {"yes"}}); status != http.StatusBadRequest {
+		t.Errorf("breaking the glass unconfirmed status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status, body := request(t, http.MethodPost, admin+"/assignments", url.Values{"confirm": {"show"}}); status != http.StatusOK || !strings.Contains(body, "<tr><td>Barney</td><td>Fred</td></tr>") {
+		t.Errorf("breaking the glass = %d:\n%s", status, body)
+	}
+	if _, body := request(t, http.MethodGet, admin, nil); !strings.Contains(body, "last shown here") || strings.Contains(body, "Buys for") {
+		t.Errorf("dashboard after breaking the glass:\n%s", body)
+	}
+	if s.Store.AssignmentsShown().IsZero() {
+		t.Errorf("breaking the glass wasn't recorded in the store")
+	}
+
+	// A drop that fails before the draw is saved keeps the sign up, and
+	// one that fails after only when telling the others keeps nothing
+	s.Drop = func(ctx context.Context, name string) error {
+		if name == "Barney" {
+			// Barney is first in the draw
+			draw.Assignment.Pairs = draw.Assignment.Pairs[1:]
+		}
+		return errors.New("error sending email")
+	}
+	for _, name := range []string{"Fred", "Barney"} {
+		if status, _ := request(t, http.MethodPost, admin+"/drop", url.Values{"name": {name}}); status != http.StatusInternalServerError {
+			t.Errorf("failed drop of %s status = %d, want %d", name, status, http.StatusInternalServerError)
+		}
+	}
+	if got := s.Store.Registrations(); len(got) != 1 || got[0].Name != "Fred" {
+		t.Errorf("sign ups after failed drops = %+v, want only Fred", got)
+	}
+	// Names drawn but not sent yet are still in the saved draw
+	deliveries, dropped = nil, nil
+	s.Drop = func(ctx context.Context, name string) error {
+		dropped = append(dropped, name)
+		return nil
+	}
+	if status, _ := request(t, http.MethodPost, admin+"/drop", url.Values{"name": {"Fred"}}); status != http.StatusSeeOther || !slices.Equal(dropped, []string{"Fred"}) {
+		t.Errorf("dropping out before anything was sent status = %d, dropped %v", status, dropped)
+	}
+	if got := s.Store.Registrations(); len(got) != 0 {
+		t.Errorf("sign ups after dropping Fred = %+v, want none", got)
+	}
+}
@@ -0,0 +1,192 @@
+package sesmailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/mimemessage"
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+const (
+	sendPath = "/v2/email/outbound-emails"
+	service  = "ses"
+)
+
+// SESEmailer sends through the Amazon SES v2 SendEmail api, or any service
+// that speaks the same protocol. Requests are signed with AWS signature
+// version 4.
+type SESEmailer struct {
+	accessKey string
+	secretKey string
+	region    string
+	baseURL   string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewSESEmailer returns an emailer for the api at baseURL, or the SES
+// endpoint for region when baseURL is empty.
+func NewSESEmailer(accessKey, secretKey, region, baseURL string) *SESEmailer {
+	if region == "" {
+		region = "us-east-1"
+	}
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://email.%s.amazonaws.com", region)
+	}
+	return &SESEmailer{
+		accessKey: accessKey,
+		secretKey: secretKey,
+		region:    region,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		now:       time.Now,
+	}
+}
+
+type content struct {
+	Data string
+}
+
+type simple struct {
+	Subject content
+	Body    struct {
+		Text content
+	}
+}
+
+type message struct {
+	FromEmailAddress string
+	Destination      struct {
+		ToAddresses []string
+	}
+	Content struct {
+		Simple *simple `json:",omitempty"`
+		// Raw carries the whole MIME message, which is needed for
+		// attachments.
+		Raw *struct {
+			Data []byte
+		} `json:",omitempty"`
+	}
+}
+
+func (m *SESEmailer) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering email: %v", err)
+	}
+	msg := message{}
+	from := mail.Address{Name: emailTemplate.SenderName, Address: emailTemplate.SenderEmail}
+	to := mail.Address{Name: gifter.Name, Address: gifter.Email}
+	msg.FromEmailAddress = from.String()
+	msg.Destination.ToAddresses = []string{to.String()}
+	if len(emailTemplate.Attachments) == 0 {
+		// SES sets the Message-ID itself and refuses one given as a header
+		msg.Content.Simple = &simple{Subject: content{Data: emailTemplate.Subject}}
+		msg.Content.Simple.Body.Text.Data = body
+	} else {
+		raw, err := mimemessage.Build(mimemessage.Header{
+			From:      from,
+			To:        to,
+			Subject:   emailTemplate.Subject,
+			Date:      m.now(),
+			MessageID: send.MessageID(ctx, emailTemplate.SenderEmail),
+		}, body, emailTemplate.Attachments)
+		if err != nil {
+			return "", err
+		}
+		msg.Content.Raw = &struct{ Data []byte }{Data: raw}
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("error encoding email: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+sendPath, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	m.sign(req, payload)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending email with ses: %v", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("error reading ses response: %v", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(raw, &failure)
+		return "", fmt.Errorf("ses returned %s: %s", resp.Status, failure.Message)
+	}
+	var result struct {
+		MessageId string
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("error decoding ses response: %v", err)
+	}
+	slog.Debug("email accepted", "provider", "ses", "id", result.MessageId, "status", resp.Status)
+	return result.MessageId, nil
+}
+
+// sign adds an AWS signature version 4 Authorization header to req.
+func (m *SESEmailer) sign(req *http.Request, payload []byte) {
+	now := m.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	payloadHash := sha256.Sum256(payload)
+	signedHeaders := "content-type;host;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		(&url.URL{Path: req.URL.Path}).EscapedPath(),
+		req.URL.RawQuery,
+		"content-type:" + req.Header.Get("Content-Type") + "\n" +
+			"host:" + req.URL.Host + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{date, m.region, service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+m.secretKey), date)
+	key = hmacSHA256(key, m.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		m.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
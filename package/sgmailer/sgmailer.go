@@ -0,0 +1,115 @@
+package sgmailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+const DefaultBaseURL = "https://api.sendgrid.com"
+
+// SendGridEmailer sends through the SendGrid v3 mail send api.
+type SendGridEmailer struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+// NewSendGridEmailer returns an emailer for the api at baseURL, or
+// SendGrid itself when baseURL is empty.
+func NewSendGridEmailer(apiKey, baseURL string) *SendGridEmailer {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &SendGridEmailer{
+		apiKey:  apiKey,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type address struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type content struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type attachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type,omitempty"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+}
+
+type message struct {
+	Personalizations []struct {
+		To []address `json:"to"`
+	} `json:"personalizations"`
+	From        address           `json:"from"`
+	Subject     string            `json:"subject"`
+	Content     []content         `json:"content"`
+	Attachments []attachment      `json:"attachments,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+func (m *SendGridEmailer) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering email: %v", err)
+	}
+	msg := message{
+		From:    address{Email: emailTemplate.SenderEmail, Name: emailTemplate.SenderName},
+		Subject: emailTemplate.Subject,
+		Content: []content{{Type: "text/plain", Value: body}},
+	}
+	for _, a := range emailTemplate.Attachments {
+		msg.Attachments = append(msg.Attachments, attachment{
+			Content:     base64.StdEncoding.EncodeToString(a.Data),
+			Type:        a.ContentType,
+			Filename:    a.Filename,
+			Disposition: "attachment",
+		})
+	}
+	if id := send.MessageID(ctx, emailTemplate.SenderEmail); id != "" {
+		msg.Headers = map[string]string{"Message-ID": id}
+	}
+	msg.Personalizations = make([]struct {
+		To []address `json:"to"`
+	}, 1)
+	msg.Personalizations[0].To = []address{{Email: gifter.Email, Name: gifter.Name}}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("error encoding email: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/v3/mail/send", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending email with sendgrid: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("sendgrid returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	slog.Debug("email accepted", "provider", "sendgrid", "id", resp.Header.Get("X-Message-Id"), "status", resp.Status)
+	return resp.Header.Get("X-Message-Id"), nil
+}
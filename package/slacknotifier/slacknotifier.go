@@ -0,0 +1,122 @@
+package slacknotifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+const DefaultBaseURL = "https://slack.com"
+
+// SlackNotifier sends each gifter a direct message from a Slack bot. The
+// participant's handle is their Slack member id; without one the bot looks
+// them up by email, which needs the users:read.email scope.
+type SlackNotifier struct {
+	token   string
+	baseURL string
+	client  *http.Client
+}
+
+// NewSlackNotifier returns a notifier for the api at baseURL, or Slack
+// itself when baseURL is empty. token is a bot token with the chat:write and
+// im:write scopes.
+func NewSlackNotifier(token, baseURL string) *SlackNotifier {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &SlackNotifier{
+		token:   token,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *SlackNotifier) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering message: %v", err)
+	}
+	user := gifter.Handle
+	if user == "" {
+		var found struct {
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		}
+		if err := s.call(ctx, http.MethodGet, "users.lookupByEmail?email="+url.QueryEscape(gifter.Email), nil, &found); err != nil {
+			return "", fmt.Errorf("error finding %s on slack: %v", gifter.Name, err)
+		}
+		user = found.User.ID
+	}
+
+	var opened struct {
+		Channel struct {
+			ID string `json:"id"`
+		} `json:"channel"`
+	}
+	if err := s.call(ctx, http.MethodPost, "conversations.open", map[string]string{"users": user}, &opened); err != nil {
+		return "", fmt.Errorf("error opening a direct message with %s: %v", gifter.Name, err)
+	}
+	var posted struct {
+		TS string `json:"ts"`
+	}
+	err = s.call(ctx, http.MethodPost, "chat.postMessage", map[string]string{
+		"channel": opened.Channel.ID,
+		"text":    fmt.Sprintf("*%s*\n\n%s", emailTemplate.Subject, body),
+	}, &posted)
+	if err != nil {
+		return "", fmt.Errorf("error messaging %s on slack: %v", gifter.Name, err)
+	}
+	slog.Debug("message sent", "provider", "slack", "id", posted.TS, "channel", opened.Channel.ID)
+	return posted.TS, nil
+}
+
+// call makes a Slack web api request. Slack reports failures in the body
+// with ok set to false rather than with a status code.
+func (s *SlackNotifier) call(ctx context.Context, method, endpoint string, in, out any) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+"/api/"+endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("slack returned %s", resp.Status)
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("error decoding slack response: %v", err)
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("error decoding slack response: %v", err)
+	}
+	if !status.OK {
+		return fmt.Errorf("slack returned %s", status.Error)
+	}
+	return json.Unmarshal(raw, out)
+}
@@ -0,0 +1,232 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dcmcand/go-secret-santa/package/filestore"
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+// ErrClosed is returned when someone signs up after registration closed.
+var ErrClosed = errors.New("registration is closed")
+
+// Registration is what a participant entered when they signed up.
+type Registration struct {
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Interests  []string  `json:"interests,omitempty"`
+	Exclusions []string  `json:"exclusions,omitempty"`
+	Address    string    `json:"address,omitempty"`
+	Country    string    `json:"country,omitempty"`
+	Registered time.Time `json:"registered"`
+}
+
+// Participant converts the registration for a draw.
+func (r Registration) Participant() send.Participant {
+	return send.Participant{
+		Name:       r.Name,
+		Email:      r.Email,
+		Interests:  r.Interests,
+		Exclusions: r.Exclusions,
+		Address:    r.Address,
+		Country:    r.Country,
+	}
+}
+
+type data struct {
+	// InviteToken is part of the sign up link, so only people sent the
+	// link can sign up.
+	InviteToken string `json:"invite_token"`
+	Closed      bool   `json:"closed"`
+	// AssignmentsShown is when the organiser last looked at every
+	// assignment.
+	AssignmentsShown time.Time      `json:"assignments_shown"`
+	Registrations    []Registration `json:"registrations"`
+}
+
+// Store keeps sign ups in a JSON file. Every change is written straight
+// away, so the file is always up to date for a Loader.
+type Store struct {
+	path string
+	mu   sync.Mutex
+	data data
+	now  func() time.Time
+}
+
+// Open reads the store at path, creating it with a new invite token if it
+// doesn't exist.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, now: time.Now}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		token := make([]byte, 16)
+		rand.Read(token)
+		s.data.InviteToken = hex.EncodeToString(token)
+		return s, s.save()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening store: %v", err)
+	}
+	if err := json.Unmarshal(raw, &s.data); err != nil {
+		return nil, fmt.Errorf("error reading store %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// InviteToken returns the token in the sign up link.
+func (s *Store) InviteToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.InviteToken
+}
+
+// IsOpen reports whether people can still sign up.
+func (s *Store) IsOpen() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.data.Closed
+}
+
+// CloseRegistration stops anyone else signing up.
+func (s *Store) CloseRegistration() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Closed = true
+	return s.save()
+}
+
+// ShowAssignments records that the organiser is looking at every
+// assignment, so it is still known after a restart.
+func (s *Store) ShowAssignments() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.AssignmentsShown = s.now().UTC().Truncate(time.Second)
+	return s.save()
+}
+
+// AssignmentsShown is when the organiser last looked at every assignment,
+// or the zero time if they never have.
+func (s *Store) AssignmentsShown() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.AssignmentsShown
+}
+
+// Register adds a participant. Each email can only sign up once, so
+// nobody can replace someone else's registration by signing up with their
+// address; the organiser corrects or removes registrations instead.
+func (s *Store) Register(r Registration) error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Email = strings.TrimSpace(r.Email)
+	if r.Name == "" || r.Email == "" {
+		return fmt.Errorf("a name and email are required")
+	}
+	if addr, err := mail.ParseAddress(r.Email); err != nil || addr.Address != r.Email {
+		return fmt.Errorf("%q is not a valid email address", r.Email)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Closed {
+		return ErrClosed
+	}
+	if r.Registered.IsZero() {
+		r.Registered = s.now().UTC().Truncate(time.Second)
+	}
+	for _, e := range s.data.Registrations {
+		if strings.EqualFold(e.Email, r.Email) {
+			return fmt.Errorf("%s has already signed up, ask the organiser if you need to change your details", r.Email)
+		}
+		if strings.EqualFold(e.Name, r.Name) {
+			return fmt.Errorf("someone called %s has already signed up, please add your surname or a nickname", r.Name)
+		}
+	}
+	s.data.Registrations = append(s.data.Registrations, r)
+	return s.save()
+}
+
+// SetEmail corrects someone's email address, even after registration has
+// closed.
+func (s *Store) SetEmail(name, email string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return fmt.Errorf("an email is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.data.Registrations, func(r Registration) bool { return r.Name == name })
+	if i < 0 {
+		return fmt.Errorf("nobody called %s has signed up", name)
+	}
+	for j, r := range s.data.Registrations {
+		if j != i && strings.EqualFold(r.Email, email) {
+			return fmt.Errorf("%s has already signed up with %s", r.Name, email)
+		}
+	}
+	s.data.Registrations[i].Email = email
+	return s.save()
+}
+
+// Remove takes someone's registration out of the store.
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.data.Registrations, func(r Registration) bool { return r.Name == name })
+	if i < 0 {
+		return fmt.Errorf("nobody called %s has signed up", name)
+	}
+	s.data.Registrations = slices.Delete(s.data.Registrations, i, i+1)
+	return s.save()
+}
+
+// Registrations lists everyone who has signed up, in the order they did.
+func (s *Store) Registrations() []Registration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.data.Registrations)
+}
+
+// save writes the store so a crash never leaves half of it behind.
+func (s *Store) save() error {
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding store: %v", err)
+	}
+	if err := filestore.WriteFile(s.path, append(raw, '\n')); err != nil {
+		return fmt.Errorf("error saving store: %v", err)
+	}
+	return nil
+}
+
+// Loader reads participants from a store, so a draw can be made from the
+// people who signed up.
+type Loader struct{}
+
+func (l Loader) LoadParticipants(ctx context.Context, path string) (send.Participants, error) {
+	if err := ctx.Err(); err != nil {
+		return send.Participants{}, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return send.Participants{}, fmt.Errorf("error opening store: %v", err)
+	}
+	var d data
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return send.Participants{}, fmt.Errorf("error reading store %s: %v", path, err)
+	}
+	p := make(send.Participants, len(d.Registrations))
+	for _, r := range d.Registrations {
+		p[r.Name] = r.Participant()
+	}
+	return p, nil
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStore_Register(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signups.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if s.InviteToken() == "" {
+		t.Fatalf("a new store has no invite token")
+	}
+
+	tests := []struct {
+		name    string
+		reg     Registration
+		wantErr bool
+	}{
+		{name: "New participant", reg: Registration{Name: "Fred", Email: "fred@bedrock.org", Exclusions: []string{"Wilma"}}},
+		{name: "Another participant", reg: Registration{Name: "Wilma", Email: "wilma@bedrock.org"}},
+		{name: "Same email can't replace a registration", reg: Registration{Name: "Barney", Email: "FRED@bedrock.org", Address: "1 Cobblestone Way"}, wantErr: true},
+		{name: "Same email and name", reg: Registration{Name: "Fred", Email: "fred@bedrock.org"}, wantErr: true},
+		{name: "Taken name", reg: Registration{Name: "wilma", Email: "other@bedrock.org"}, wantErr: true},
+		{name: "Missing email", reg: Registration{Name: "Barney"}, wantErr: true},
+		{name: "Bad email", reg: Registration{Name: "Barney", Email: "barney at bedrock"}, wantErr: true},
+		{name: "Named email", reg: Registration{Name: "Barney", Email: "Barney <barney@bedrock.org>"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Register(tt.reg); (err != nil) != tt.wantErr {
+				t.Errorf("Store.Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := s.CloseRegistration(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register(Registration{Name: "Barney", Email: "barney@bedrock.org"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Store.Register() after closing error = %v, want ErrClosed", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if reopened.IsOpen() || reopened.InviteToken() != s.InviteToken() {
+		t.Errorf("reopened store lost its state")
+	}
+	p, err := Loader{}.LoadParticipants(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Loader.LoadParticipants() error = %v", err)
+	}
+	if len(p) != 2 {
+		t.Fatalf("loaded %d participants, want 2", len(p))
+	}
+	if fred := p["Fred"]; fred.Address != "" || !slices.Equal(fred.Exclusions, []string{"Wilma"}) {
+		t.Errorf("Fred = %+v, want the details they first signed up with", fred)
+	}
+	names := []string{}
+	for _, r := range reopened.Registrations() {
+		names = append(names, r.Name)
+	}
+	if !slices.Equal(names, []string{"Fred", "Wilma"}) {
+		t.Errorf("Registrations() = %v, want them in sign up order", names)
+	}
+}
+
+func TestStore_SetEmailRemove(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "signups.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []Registration{{Name: "Fred", Email: "fred@bedrock.org"}, {Name: "Wilma", Email: "wilma@bedrock.org"}} {
+		if err := s.Register(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.CloseRegistration(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetEmail("Fred", "fred@slate.com"); err != nil {
+		t.Errorf("Store.SetEmail() after closing error = %v", err)
+	}
+	if err := s.SetEmail("Fred", "WILMA@bedrock.org"); err == nil {
+		t.Errorf("Store.SetEmail() to someone else's email succeeded")
+	}
+	if err := s.SetEmail("Barney", "barney@bedrock.org"); err == nil {
+		t.Errorf("Store.SetEmail() for someone who hasn't signed up succeeded")
+	}
+	if err := s.Remove("Wilma"); err != nil {
+		t.Errorf("Store.Remove() error = %v", err)
+	}
+	if got := s.Registrations(); len(got) != 1 || got[0].Email != "fred@slate.com" {
+		t.Errorf("Store.Registrations() = %+v", got)
+	}
+}
+
+func TestStore_ShowAssignments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signups.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.AssignmentsShown().IsZero() {
+		t.Errorf("a new store says the assignments were shown")
+	}
+	s.now = func() time.Time { return time.Date(2026, 12, 1, 18, 30, 0, 0, time.UTC) }
+	if err := s.ShowAssignments(); err != nil {
+		t.Fatalf("Store.ShowAssignments() error = %v", err)
+	}
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.AssignmentsShown(); !got.Equal(s.now()) {
+		t.Errorf("reopened Store.AssignmentsShown() = %v, want %v", got, s.now())
+	}
+}
@@ -1,22 +1,86 @@
 package template
 
 import (
-	"fmt"
+	"path/filepath"
 	"text/template"
 
 	"github.com/dcmcand/go-secret-santa/package/send"
 )
 
 func GetDefaultTemplate(subject, senderName, senderEmail string) (*send.Email, error) {
-	fmt.Println("getting default template")
 	tmplSrc := `Hello {{.Gifter.Name}},
 This is your secret santa assignment!
-This Christmas, you will buy a gift for {{.Giftee.Name}}.
-{{.Giftee.Name}} wrote in their letter to Santa that they are interested in {{.Giftee.Interests}}.
+This Christmas, you will buy {{if gt (len .Giftees) 1}}gifts{{else}}a gift{{end}} for {{.GifteeNames}}.
+{{- range $giftee := .Giftees}}
+{{- with $giftee.Interests}}
+{{$giftee.Name}} wrote in their letter to Santa that they are interested in {{range $i, $interest := .}}{{if $i}}, {{end}}{{$interest}}{{end}}.
+{{- end}}
+{{- with $giftee.Wishlist}}
+{{$giftee.Name}}'s wishlist:
+{{- range .}}
+  - {{.}}
+{{- end}}
+{{- end}}
+{{- with $giftee.Address}}
+Send {{$giftee.Name}}'s gift to: {{.}}
+{{- end}}
+{{- with relayLink $giftee.Name}}
+Ask {{$giftee.Name}} a question without giving yourself away: {{.}}
+{{- end}}
+{{- end}}
 Remember this is a SECRET Santa so ssssshhhhhhh!
 Merry Christmas
 Santa Claus`
-	tmpl, err := template.New("default").Parse(tmplSrc)
+	tmpl, err := template.New("default").Funcs(RelayFuncs).Parse(tmplSrc)
+	if err != nil {
+		return nil, err
+	}
+	return &send.Email{
+		Subject:     subject,
+		SenderName:  senderName,
+		SenderEmail: senderEmail,
+		Body:        tmpl,
+	}, nil
+}
+
+// GetDefaultSMSTemplate is a short plain text template that fits the
+// assignment and top wish into a single text message.
+func GetDefaultSMSTemplate(subject, senderName, senderEmail string) (*send.Email, error) {
+	tmplSrc := `Hi {{.Gifter.Name}}! Secret Santa: you're buying for {{.GifteeNames}}.
+{{- range .Giftees}}{{$name := .Name}}{{with .Wishlist}} {{$name}} wants {{(index . 0).Name}}.{{end}}{{end}} Shh!`
+	tmpl, err := template.New("sms").Parse(tmplSrc)
+	if err != nil {
+		return nil, err
+	}
+	return &send.Email{
+		Subject:     subject,
+		SenderName:  senderName,
+		SenderEmail: senderEmail,
+		Body:        tmpl,
+	}, nil
+}
+
+// GetDefaultEnvelopeTemplate is printed inside sealed envelopes. The
+// gifter's name is already on the outside, so it focuses on the giftees.
+func GetDefaultEnvelopeTemplate(subject, senderName, senderEmail string) (*send.Email, error) {
+	tmplSrc := `You are buying for {{.GifteeNames}}!
+{{- range $giftee := .Giftees}}
+
+{{$giftee.Name}}
+{{- with $giftee.Interests}}
+Interested in {{range $i, $interest := .}}{{if $i}}, {{end}}{{$interest}}{{end}}.
+{{- end}}
+{{- with $giftee.Wishlist}}
+Wishlist:
+{{- range .}}
+  - {{.}}
+{{- end}}
+{{- end}}
+{{- end}}
+
+Remember this is a SECRET Santa so ssssshhhhhhh!
+{{.Gifter.Name}}, keep this envelope somewhere safe.`
+	tmpl, err := template.New("envelope").Parse(tmplSrc)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +93,49 @@ Santa Claus`
 }
 
 func GetTemplate(tmplSrc, subject, senderName, senderEmail string) (*send.Email, error) {
-	tmpl, err := template.New("custom").ParseFiles(tmplSrc)
+	// ParseFiles names the template after the file, so it must be created
+	// with that name for Execute to find it
+	tmpl, err := template.New(filepath.Base(tmplSrc)).Funcs(RelayFuncs).ParseFiles(tmplSrc)
+	if err != nil {
+		return nil, err
+	}
+	return &send.Email{
+		Subject:     subject,
+		SenderName:  senderName,
+		SenderEmail: senderEmail,
+		Body:        tmpl,
+	}, nil
+
+}
+
+// RelayFuncs are the functions assignment templates can call when
+// anonymous messages are relayed: relayLink takes a giftee's name and
+// returns the link to message them, or "" when messages aren't relayed.
+var RelayFuncs = template.FuncMap{
+	"relayLink": func(giftee string) string { return "" },
+}
+
+// RelayMessageFuncs are the functions relayed message templates call:
+// relayFrom says who sent the message without giving a gifter away,
+// relayMessage is what they wrote and relayReply is the link to answer.
+var RelayMessageFuncs = template.FuncMap{
+	"relayFrom":    func() string { return "" },
+	"relayMessage": func() string { return "" },
+	"relayReply":   func() string { return "" },
+}
+
+// GetDefaultRelayTemplate carries a relayed message between a gifter and
+// their giftee.
+func GetDefaultRelayTemplate(subject, senderName, senderEmail string) (*send.Email, error) {
+	tmplSrc := `Hello {{.Gifter.Name}},
+{{relayFrom}} sent you a message:
+
+{{relayMessage}}
+
+Reply here: {{relayReply}}
+Replies go through Santa, so nobody's secret is given away.
+Santa Claus`
+	tmpl, err := template.New("relay").Funcs(RelayMessageFuncs).Parse(tmplSrc)
 	if err != nil {
 		return nil, err
 	}
@@ -39,5 +145,64 @@ func GetTemplate(tmplSrc, subject, senderName, senderEmail string) (*send.Email,
 		SenderEmail: senderEmail,
 		Body:        tmpl,
 	}, nil
+}
+
+// RevealFuncs are the functions reveal templates can call: revealLink is
+// the link to the gifter's assignment and revealExpires is when it stops
+// working. They are filled in as each message is sent.
+var RevealFuncs = template.FuncMap{
+	"revealLink":    func() string { return "" },
+	"revealExpires": func() string { return "" },
+}
+
+// GetDefaultRevealTemplate is sent instead of the assignment when
+// assignments are revealed on a web page. It only has the link.
+func GetDefaultRevealTemplate(subject, senderName, senderEmail string) (*send.Email, error) {
+	tmplSrc := `Hello {{.Gifter.Name}},
+Names have been drawn for Secret Santa! Open this link when nobody is looking to find out who you're buying for:
+{{revealLink}}
+The link only works a few times and expires on {{revealExpires}}, so make a note of your assignment.
+Merry Christmas
+Santa Claus`
+	tmpl, err := template.New("reveal").Funcs(RevealFuncs).Parse(tmplSrc)
+	if err != nil {
+		return nil, err
+	}
+	return &send.Email{
+		Subject:     subject,
+		SenderName:  senderName,
+		SenderEmail: senderEmail,
+		Body:        tmpl,
+	}, nil
+}
 
+// GetDefaultSMSRevealTemplate is the reveal message sent by text. It only
+// has the gifter's name and the link, so it fits in a single sms segment.
+func GetDefaultSMSRevealTemplate(subject, senderName, senderEmail string) (*send.Email, error) {
+	tmplSrc := `Hi {{.Gifter.Name}}, your Secret Santa: {{revealLink}}`
+	tmpl, err := template.New("sms-reveal").Funcs(RevealFuncs).Parse(tmplSrc)
+	if err != nil {
+		return nil, err
+	}
+	return &send.Email{
+		Subject:     subject,
+		SenderName:  senderName,
+		SenderEmail: senderEmail,
+		Body:        tmpl,
+	}, nil
+}
+
+// GetRevealTemplate loads a reveal template from a file. It can use
+// RevealFuncs as well as the usual template data, apart from the giftees.
+func GetRevealTemplate(tmplSrc, subject, senderName, senderEmail string) (*send.Email, error) {
+	tmpl, err := template.New(filepath.Base(tmplSrc)).Funcs(RevealFuncs).ParseFiles(tmplSrc)
+	if err != nil {
+		return nil, err
+	}
+	return &send.Email{
+		Subject:     subject,
+		SenderName:  senderName,
+		SenderEmail: senderEmail,
+		Body:        tmpl,
+	}, nil
 }
@@ -0,0 +1,128 @@
+package twilionotifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+const DefaultBaseURL = "https://api.twilio.com"
+
+// TwilioNotifier texts each gifter through the Twilio messages api, or any
+// service with the same interface. Messages longer than MaxSegments sms
+// segments are refused rather than sent as a long, expensive text.
+type TwilioNotifier struct {
+	accountSID  string
+	authToken   string
+	from        string
+	baseURL     string
+	MaxSegments int
+	client      *http.Client
+}
+
+// NewTwilioNotifier sends from the phone number or sender id from. An
+// empty baseURL uses Twilio itself.
+func NewTwilioNotifier(accountSID, authToken, from, baseURL string) *TwilioNotifier {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &TwilioNotifier{
+		accountSID:  accountSID,
+		authToken:   authToken,
+		from:        from,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		MaxSegments: 1,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type response struct {
+	SID       string `json:"sid"`
+	Status    string `json:"status"`
+	ErrorCode int    `json:"code"`
+	Message   string `json:"message"`
+}
+
+func (t *TwilioNotifier) Notify(ctx context.Context, gifter send.Participant, giftees []send.Participant, emailTemplate *send.Email) (string, error) {
+	if gifter.Phone == "" {
+		return "", fmt.Errorf("%s has no phone number", gifter.Name)
+	}
+	body, err := emailTemplate.Render(gifter, giftees...)
+	if err != nil {
+		return "", fmt.Errorf("error rendering message: %v", err)
+	}
+	body = strings.TrimSpace(body)
+	if n, limit := Segments(body), max(t.MaxSegments, 1); n > limit {
+		return "", fmt.Errorf("the text for %s from template %q needs %d sms segments but only %d are allowed, shorten the template or raise segments", gifter.Name, emailTemplate.Body.Name(), n, limit)
+	}
+
+	form := url.Values{}
+	form.Set("To", gifter.Phone)
+	form.Set("From", t.from)
+	form.Set("Body", body)
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", t.baseURL, url.PathEscape(t.accountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	req.SetBasicAuth(t.accountSID, t.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error texting %s: %v", gifter.Name, err)
+	}
+	defer resp.Body.Close()
+	var result response
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	json.Unmarshal(raw, &result)
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("twilio returned %s: %d %s", resp.Status, result.ErrorCode, result.Message)
+	}
+	slog.Debug("text accepted", "provider", "twilio", "id", result.SID, "status", result.Status)
+	return result.SID, nil
+}
+
+// gsm7 holds the characters of the GSM 03.38 basic character set. Messages
+// using only these fit 160 characters in a segment, anything else drops the
+// message to 70.
+const gsm7 = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extended characters are in the GSM extension table and take two
+// characters of space each.
+const gsm7Extended = "^{}\\[~]|€\f"
+
+// Segments reports how many sms segments body needs.
+func Segments(body string) int {
+	length := 0
+	for _, r := range body {
+		switch {
+		case strings.ContainsRune(gsm7, r):
+			length++
+		case strings.ContainsRune(gsm7Extended, r):
+			length += 2
+		default:
+			// Anything outside the GSM set sends the whole message as UCS-2
+			return segments(len(utf16.Encode([]rune(body))), 70, 67)
+		}
+	}
+	return segments(length, 160, 153)
+}
+
+// segments splits length characters into segments holding single
+// characters on their own, or multi each when the message is split.
+func segments(length, single, multi int) int {
+	if length <= single {
+		return 1
+	}
+	return (length + multi - 1) / multi
+}
@@ -0,0 +1,81 @@
+package twilionotifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/dcmcand/go-secret-santa/package/send"
+)
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "Short message", body: "Hi Fred!", want: 1},
+		{name: "160 GSM characters fit one segment", body: strings.Repeat("a", 160), want: 1},
+		{name: "161 GSM characters need two", body: strings.Repeat("a", 161), want: 2},
+		{name: "Extended characters count twice", body: strings.Repeat("€", 81), want: 2},
+		{name: "Unicode fits 70 characters", body: strings.Repeat("🎁", 35), want: 1},
+		{name: "Unicode over 70 characters", body: "🎁" + strings.Repeat("a", 69), want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Segments(tt.body); got != tt.want {
+				t.Errorf("Segments() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTwilioNotifier_Notify(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2010-04-01/Accounts/AC1/Messages.json" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		if user, pass, _ := r.BasicAuth(); user != "AC1" || pass != "token" {
+			t.Errorf("basic auth = %s:%s, want AC1:token", user, pass)
+		}
+		r.ParseForm()
+		if r.Form.Get("To") != "+15555550123" || r.Form.Get("From") != "+15555550100" {
+			t.Errorf("unexpected To/From %v", r.Form)
+		}
+		got = r.Form.Get("Body")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid":"SM1","status":"queued"}`))
+	}))
+	defer server.Close()
+
+	n := NewTwilioNotifier("AC1", "token", "+15555550100", server.URL)
+	email := &send.Email{Body: template.Must(template.New("sms").Parse("Hi {{.Gifter.Name}}, you're buying for {{.Giftee.Name}}"))}
+	gifter := send.Participant{Name: "Fred", Phone: "+15555550123"}
+	if _, err := n.Notify(context.Background(), gifter, []send.Participant{{Name: "Wilma"}}, email); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if want := "Hi Fred, you're buying for Wilma"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+
+	got = ""
+	long := &send.Email{Body: template.Must(template.New("long.tmpl").Parse(strings.Repeat("{{.Giftee.Name}} ", 50)))}
+	_, err := n.Notify(context.Background(), gifter, []send.Participant{{Name: "Wilma"}}, long)
+	if err == nil || !strings.Contains(err.Error(), `template "long.tmpl" needs 2 sms segments`) {
+		t.Errorf("Notify() error = %v, want one naming the template and its length", err)
+	}
+	if got != "" {
+		t.Errorf("sent %q, want nothing sent when the text is too long", got)
+	}
+	n.MaxSegments = 2
+	if _, err := n.Notify(context.Background(), gifter, []send.Participant{{Name: "Wilma"}}, long); err != nil {
+		t.Errorf("Notify() error = %v with enough segments allowed", err)
+	}
+	if _, err := n.Notify(context.Background(), send.Participant{Name: "Barney"}, nil, email); err == nil {
+		t.Errorf("Notify() without a phone number should return an error")
+	}
+}